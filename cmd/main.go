@@ -1,15 +1,29 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	_ "github.com/rezexell/em-test-task/docs"
 	"github.com/rezexell/em-test-task/internal/config"
 	"github.com/rezexell/em-test-task/internal/handler"
+	"github.com/rezexell/em-test-task/internal/metrics"
 	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/internal/notifier"
 	"github.com/rezexell/em-test-task/internal/repository"
 	"github.com/rezexell/em-test-task/internal/service"
+	"github.com/rezexell/em-test-task/internal/tracing"
+	"github.com/rezexell/em-test-task/pkg/jwt"
 	"github.com/rezexell/em-test-task/pkg/postgres"
+	"github.com/rezexell/em-test-task/pkg/ratelimit"
 	"github.com/rezexell/em-test-task/pkg/slogger"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 )
 
 // @title Subscriptions API
@@ -17,8 +31,35 @@ import (
 // @description This is a sample API for managing subscriptions
 // @host localhost:3000
 // @BasePath /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT в формате "Bearer <token>"
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description Ключ API с правами subs:read, subs:write, reports:read или admin
 func main() {
-	cfg := config.InitConfig()
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	// The configuration is printed even when invalid to help find the
+	// source of a bad setting.
+	if cfg != nil && cfg.PRINTCONFIG {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	if cfg.PRINTCONFIG {
+		return
+	}
+
 	model.RegisterCustomBindings()
 	logger := slogger.InitLogger(cfg)
 	logger.Info("Logger initialized")
@@ -27,13 +68,174 @@ func main() {
 
 	db := postgres.InitDB(cfg, logger)
 
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.TRACINGEXPORTER,
+		ServiceName: cfg.TRACINGSERVICENAME,
+		Endpoint:    cfg.TRACINGENDPOINT,
+		SampleRatio: cfg.TRACINGSAMPLERATIO,
+	})
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	appMetrics := metrics.New()
+	if err := appMetrics.InstrumentDB(db, cfg.DBNAME); err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
 	repos := repository.NewRepository(db)
 	services := service.NewService(repos)
-	h := handler.NewHandler(services, logger)
 
-	server := h.InitRouter()
-	if err := server.Run(":3000"); err != nil {
+	expiryNotifier, err := notifier.New(cfg, logger)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// Background workers are stopped only after the server has drained,
+	// since in-flight requests may still queue work for them.
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	if expiryNotifier != nil {
+		scheduler := service.NewExpiryScheduler(repos.Notification, expiryNotifier, cfg.NOTIFYDAYS, cfg.NOTIFYINTERVAL, logger)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			scheduler.Run(workersCtx)
+		}()
+	}
+
+	dispatcher := service.NewWebhookDispatcher(repos.Webhook, cfg.WEBHOOKPOLLINTERVAL, logger)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		dispatcher.Run(workersCtx)
+	}()
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		appMetrics.RunKPIs(workersCtx, repos.ServiceStats, cfg.METRICSKPIINTERVAL, logger)
+	}()
+
+	verifier, err := newVerifier(cfg)
+	if err != nil {
 		logger.Error(err.Error())
 		os.Exit(1)
 	}
+	if cfg.AUTHDEVMODE {
+		logger.Warn("AUTH_DEV_MODE is enabled, requests without credentials act as the user in X-User-ID")
+	}
+	h := handler.NewHandler(services, handler.Options{
+		Verifier:       verifier,
+		DevAuth:        cfg.AUTHDEVMODE,
+		Limiter:        ratelimit.New(ratelimit.NewMemoryStore(), cfg.RATELIMIT, cfg.RATELIMITROUTES),
+		IPLimiter:      ratelimit.New(ratelimit.NewMemoryStore(), cfg.RATELIMITIP, nil),
+		Checks:         map[string]handler.ReadinessCheck{"database": postgres.HealthCheck(db)},
+		Metrics:        appMetrics,
+		WriteTimeout:   cfg.HTTPWRITETIMEOUT,
+		TrustedProxies: cfg.TRUSTEDPROXIES,
+	}, logger)
+
+	server := &http.Server{
+		Addr:              cfg.HTTPADDR,
+		Handler:           h.InitRouter(),
+		ReadTimeout:       cfg.HTTPREADTIMEOUT,
+		ReadHeaderTimeout: cfg.HTTPREADHEADERTIMEOUT,
+		WriteTimeout:      cfg.HTTPWRITETIMEOUT,
+		IdleTimeout:       cfg.HTTPIDLETIMEOUT,
+		MaxHeaderBytes:    cfg.HTTPMAXHEADERBYTES,
+	}
+
+	// Metrics are not authenticated, so they are kept off the API address.
+	var metricsServer *http.Server
+	if cfg.METRICSADDR != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", appMetrics.Handler())
+		metricsServer = &http.Server{
+			Addr:              cfg.METRICSADDR,
+			Handler:           mux,
+			ReadHeaderTimeout: cfg.HTTPREADHEADERTIMEOUT,
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 2)
+	go func() {
+		logger.Info("Server started", slog.String("addr", server.Addr))
+		serverErr <- server.ListenAndServe()
+	}()
+	if metricsServer != nil {
+		go func() {
+			logger.Info("Metrics server started", slog.String("addr", metricsServer.Addr))
+			if err := metricsServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- err
+			}
+		}()
+	}
+
+	exitCode := 0
+	select {
+	case err := <-serverErr:
+		logger.Error("Server failed", slog.Any("err", err.Error()))
+		exitCode = 1
+	case <-ctx.Done():
+		stop()
+		logger.Info("Shutting down", slog.Duration("timeout", cfg.SHUTDOWNTIMEOUT))
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.SHUTDOWNTIMEOUT)
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Server did not drain in time", slog.Any("err", err.Error()))
+			exitCode = 1
+		}
+		cancel()
+	}
+	if metricsServer != nil {
+		metricsServer.Close()
+	}
+
+	stopWorkers()
+	workers.Wait()
+	postgres.Close(db, logger)
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), cfg.SHUTDOWNTIMEOUT)
+	if err := shutdownTracing(flushCtx); err != nil {
+		logger.Error("Failed to flush traces", slog.Any("err", err.Error()))
+	}
+	cancel()
+	os.Exit(exitCode)
+}
+
+// newVerifier builds the JWT verifier from the config, or returns nil when
+// authentication is not configured.
+func newVerifier(cfg *config.Config) (*jwt.Verifier, error) {
+	if cfg.JWTSECRET == "" && cfg.JWTJWKSFILE == "" {
+		return nil, nil
+	}
+
+	jwtCfg := jwt.Config{
+		Issuer:   cfg.JWTISSUER,
+		Audience: cfg.JWTAUDIENCE,
+		Leeway:   cfg.JWTLEEWAY,
+	}
+	if cfg.JWTSECRET != "" {
+		jwtCfg.HMACSecret = []byte(cfg.JWTSECRET)
+	}
+	if cfg.JWTJWKSFILE != "" {
+		keys, err := jwt.LoadJWKS(cfg.JWTJWKSFILE)
+		if err != nil {
+			return nil, err
+		}
+		jwtCfg.RSAKeys = keys
+	}
+
+	return jwt.NewVerifier(jwtCfg)
 }
@@ -15,39 +15,52 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/sub": {
+        "/admin/api-keys": {
             "get": {
-                "description": "Возвращает список всех подписок",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Возвращает все ключи, включая отозванные, с временем последнего использования",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "admin"
                 ],
-                "summary": "Получить все подписки",
+                "summary": "Список ключей API",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/model.Subscription"
+                                "$ref": "#/definitions/model.APIKey"
                             }
                         }
                     },
                     "500": {
-                        "description": "Пример: {\\\"error\\\": \\\"failed to fetch subscriptions\\\"}",
+                        "description": "internal server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     }
                 }
             },
-            "put": {
-                "description": "Обновляет данные подписки по ID",
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Создает ключ для межсервисных запросов с заданными правами (subs:read, subs:write, reports:read, admin). Ключ передается в заголовке X-API-Key и возвращается только в этом ответе. Ключ с user_id действует от имени пользователя, без него - для всех пользователей",
                 "consumes": [
                     "application/json"
                 ],
@@ -55,258 +68,352 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "admin"
                 ],
-                "summary": "Обновить существующую подписку",
+                "summary": "Создать ключ API",
                 "parameters": [
                     {
-                        "description": "Обновленные данные подписки",
+                        "description": "Ключ API",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.Subscription"
+                            "$ref": "#/definitions/model.APIKey"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Пример: {\\\"id\\\": \\\"...\\\", \\\"name\\\": \\\"billing-export\\\", \\\"prefix\\\": \\\"sk_AbCdEfGh\\\", \\\"scopes\\\": [\\\"subs:read\\\"], \\\"key\\\": \\\"sk_...\\\"}",
                         "schema": {
                             "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "additionalProperties": true
                         }
                     },
                     "400": {
-                        "description": "Пример: {\\\"error\\\": \\\"start_date: required field\\\"}",
+                        "description": "invalid api key",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     },
                     "500": {
-                        "description": "Пример: {\\\"error\\\": \\\"subscription not found\\\"}",
+                        "description": "internal server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/api-keys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Отозвать ключ API",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID ключа (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "invalid UUID format",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "api key not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/exchange-rates": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Возвращает загруженные курсы валют к рублю",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Курсы валют",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.ExchangeRate"
                             }
                         }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
                     }
                 }
             },
-            "post": {
-                "description": "Создает новую подписочную запись",
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Создает или обновляет курсы валют к рублю. Принимает JSON-массив или CSV (currency,rate) с Content-Type text/csv",
                 "consumes": [
-                    "application/json"
+                    "application/json",
+                    "text/csv"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "admin"
                 ],
-                "summary": "Создать новую подписку",
+                "summary": "Загрузить курсы валют",
                 "parameters": [
                     {
-                        "description": "Данные подписки",
+                        "description": "Курсы валют",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/model.Subscription"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.ExchangeRate"
+                            }
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.ExchangeRate"
                             }
                         }
                     },
                     "400": {
-                        "description": "Пример: {\\\"error\\\": \\\"invalid UUID format\\\"}",
+                        "description": "unsupported currency XYZ",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     },
                     "500": {
-                        "description": "Пример: {\\\"error\\\": \\\"database connection failed\\\"}",
+                        "description": "internal server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     }
                 }
             }
         },
-        "/sub/filter": {
+        "/health/live": {
             "get": {
-                "description": "Возвращает подписки по фильтрам",
+                "description": "Отвечает, пока процесс обрабатывает запросы. Зависимости не проверяются",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
-                ],
-                "summary": "Фильтрация подписок",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "ID пользователя (UUID)",
-                        "name": "user_id",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Название сервиса",
-                        "name": "service_name",
-                        "in": "query"
-                    }
+                    "health"
                 ],
+                "summary": "Проверка жизнеспособности",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/model.Subscription"
-                            }
+                            "$ref": "#/definitions/handler.Health"
                         }
-                    },
-                    "400": {
-                        "description": "Пример: {\\\"error\\\": \\\"invalid user_id format\\\"}",
+                    }
+                }
+            }
+        },
+        "/health/ready": {
+            "get": {
+                "description": "Проверяет зависимости (база данных, версия миграций) и возвращает результат и задержку каждой проверки. Если хотя бы одна проверка не прошла, возвращается 503",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Проверка готовности",
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handler.Health"
                         }
                     },
-                    "500": {
-                        "description": "Пример: {\\\"error\\\": \\\"filtering failed\\\"}",
+                    "503": {
+                        "description": "Service Unavailable",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handler.Health"
                         }
                     }
                 }
             }
         },
-        "/sub/total-cost": {
+        "/sub": {
             "get": {
-                "description": "Рассчитывает общую стоимость подписок за период",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Возвращает страницу подписок (keyset-пагинация)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "subscriptions"
                 ],
-                "summary": "Расчет общей стоимости",
+                "summary": "Получить все подписки",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "ID пользователя (UUID)",
-                        "name": "user_id",
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Размер страницы (1-500)",
+                        "name": "limit",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Название сервиса",
-                        "name": "service_name",
+                        "description": "Курсор следующей страницы (next_cursor)",
+                        "name": "cursor",
                         "in": "query"
                     },
                     {
+                        "enum": [
+                            "start_date",
+                            "monthly_cost",
+                            "service_name",
+                            "created_at"
+                        ],
                         "type": "string",
-                        "example": "01/2023",
-                        "description": "Начало периода (MM/YYYY)",
-                        "name": "start_period",
-                        "in": "query",
-                        "required": true
+                        "default": "start_date",
+                        "description": "Поле сортировки",
+                        "name": "sort",
+                        "in": "query"
                     },
                     {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
                         "type": "string",
-                        "example": "12/2023",
-                        "description": "Конец периода (MM/YYYY)",
-                        "name": "end_period",
-                        "in": "query",
-                        "required": true
+                        "default": "desc",
+                        "description": "Направление сортировки",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Включать удаленные подписки",
+                        "name": "include_deleted",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Пример: {\\\"total_cost\\\": 150}",
+                        "description": "Пример: {\\\"items\\\": [], \\\"next_cursor\\\": null}",
                         "schema": {
                             "type": "object",
-                            "additionalProperties": {
-                                "type": "integer"
-                            }
+                            "additionalProperties": true
                         }
                     },
                     "400": {
-                        "description": "Пример: {\\\"error\\\": \\\"invalid end_period format, use MM/YYYY\\\"}",
+                        "description": "invalid cursor",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     },
                     "500": {
-                        "description": "Пример: {\\\"error\\\": \\\"cost calculation failed\\\"}",
+                        "description": "internal server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     }
                 }
-            }
-        },
-        "/sub/{id}": {
-            "get": {
-                "description": "Возвращает подписку по её идентификатору",
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Обновляет данные подписки по ID. Новая цена действует для списаний с сегодняшнего дня, прошедшие списания учитываются по прежней цене",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "subscriptions"
                 ],
-                "summary": "Получить подписку по ID",
+                "summary": "Обновить существующую подписку",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "ID подписки (UUID)",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Обновленные данные подписки",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/model.Subscription"
-                        }
-                    },
-                    "400": {
-                        "description": "Пример: {\\\"error\\\": \\\"invalid UUID format\\\"}",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -314,39 +421,1368 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "400": {
+                        "description": "start_date: required field",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
                     "404": {
-                        "description": "Пример: {\\\"error\\\": \\\"subscription not found\\\"}",
+                        "description": "subscription not found",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     },
                     "500": {
-                        "description": "Пример: {\\\"error\\\": \\\"database query failed\\\"}",
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Создает новую подписочную запись",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Создать новую подписку",
+                "parameters": [
+                    {
+                        "description": "Данные подписки",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
                                 "type": "string"
                             }
                         }
+                    },
+                    "400": {
+                        "description": "invalid UUID format",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "subscription already exists",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/sub/cost-breakdown": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Рассчитывает стоимость подписок за период с группировкой по месяцам, сервисам и пользователям",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reports"
+                ],
+                "summary": "Детализация стоимости",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "01/2023",
+                        "description": "Начало периода (MM/YYYY)",
+                        "name": "start_period",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "12/2023",
+                        "description": "Конец периода (MM/YYYY)",
+                        "name": "end_period",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Учитывать удаленные подписки",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "month",
+                        "description": "Группировка через запятую: month, service, user",
+                        "name": "group_by",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Пример: {\\\"group_by\\\": [\\\"month\\\"], \\\"items\\\": [{\\\"month\\\": \\\"01/2023\\\", \\\"currency\\\": \\\"RUB\\\", \\\"total_cost\\\": 400}], \\\"totals\\\": {\\\"RUB\\\": 400}}",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "unsupported group_by value \\\"day\\",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "start period cannot be after end period",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/sub/export": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Выгружает все подписки, подходящие под фильтры, в CSV, NDJSON или XLSX. Строки читаются из базы и отправляются потоком",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Экспорт подписок",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "ndjson",
+                            "xlsx"
+                        ],
+                        "type": "string",
+                        "default": "csv",
+                        "description": "Формат файла",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "03/2024",
+                        "description": "Активна начиная с (MM/YYYY)",
+                        "name": "active_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "09/2024",
+                        "description": "Активна до (MM/YYYY)",
+                        "name": "active_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Начинается после месяца (MM/YYYY)",
+                        "name": "starts_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Заканчивается до месяца (MM/YYYY)",
+                        "name": "ends_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Только бессрочные (true) или только с датой окончания (false)",
+                        "name": "open_ended",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Включать удаленные подписки",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "unsupported format \\\"pdf\\",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "active_from cannot be after active_to",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/sub/filter": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Возвращает подписки по фильтрам",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Фильтрация подписок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "03/2024",
+                        "description": "Активна начиная с (MM/YYYY)",
+                        "name": "active_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "09/2024",
+                        "description": "Активна до (MM/YYYY)",
+                        "name": "active_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Начинается после месяца (MM/YYYY)",
+                        "name": "starts_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Заканчивается до месяца (MM/YYYY)",
+                        "name": "ends_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Только бессрочные (true) или только с датой окончания (false)",
+                        "name": "open_ended",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Размер страницы (1-500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Курсор следующей страницы (next_cursor)",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "start_date",
+                            "monthly_cost",
+                            "service_name",
+                            "created_at"
+                        ],
+                        "type": "string",
+                        "default": "start_date",
+                        "description": "Поле сортировки",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "default": "desc",
+                        "description": "Направление сортировки",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Включать удаленные подписки",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Пример: {\\\"items\\\": [], \\\"next_cursor\\\": null}",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "invalid user_id format",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "active_from cannot be after active_to",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/sub/import": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Создает подписки из CSV (с заголовком из полей подписки) или NDJSON. В режиме atomic подписки создаются только если корректны все строки, в режиме partial создаются корректные строки",
+                "consumes": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Импорт подписок",
+                "parameters": [
+                    {
+                        "enum": [
+                            "atomic",
+                            "partial"
+                        ],
+                        "type": "string",
+                        "default": "atomic",
+                        "description": "Режим импорта",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "description": "CSV: service_name,price,billing_period,currency,user_id,start_date,end_date",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ImportReport"
+                        }
+                    },
+                    "400": {
+                        "description": "unsupported content type application/json, use text/csv or application/x-ndjson",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/sub/total-cost": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Рассчитывает общую стоимость списаний по подпискам за период и среднюю стоимость в месяц",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Расчет общей стоимости",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя (UUID)",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "01/2023",
+                        "description": "Начало периода (MM/YYYY)",
+                        "name": "start_period",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "12/2023",
+                        "description": "Конец периода (MM/YYYY)",
+                        "name": "end_period",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Учитывать удаленные подписки",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "RUB",
+                        "description": "Валюта итоговой суммы (ISO 4217)",
+                        "name": "target_currency",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "none",
+                            "daily"
+                        ],
+                        "type": "string",
+                        "default": "none",
+                        "description": "Учет неполных периодов: none - списания целиком, daily - пропорционально дням",
+                        "name": "proration",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Пример: {\\\"total_cost\\\": 9350.5, \\\"monthly_equivalent\\\": 779.21, \\\"currency\\\": \\\"RUB\\\", \\\"subtotals\\\": {\\\"RUB\\\": 150, \\\"USD\\\": 100}}",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "invalid end_period format, use MM/YYYY",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "start period cannot be after end period",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/sub/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Возвращает подписку по её идентификатору",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Получить подписку по ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid id",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Удаляет подписку по ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Удалить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "invalid id",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Применяет JSON Merge Patch (RFC 7396). \"end_date\": null делает подписку бессрочной. Новая цена действует для списаний с сегодняшнего дня, прошедшие списания учитываются по прежней цене. price - сумма одного списания за итоговый период: переданный billing_period, иначе сохраненный. monthly_cost заменяет price только при помесячном списании и не принимается вместе с price. Смена billing_period требует цены нового периода в том же запросе",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Частично обновить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Изменяемые поля подписки",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid end_date format, use YYYY-MM-DD or MM/YYYY",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "end_date cannot be before start_date",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/sub/{id}/history": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Возвращает версии подписки. С параметром as_of возвращает состояние подписки на указанную дату",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "История изменений подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-06-30",
+                        "description": "Дата (YYYY-MM-DD или RFC 3339)",
+                        "name": "as_of",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "invalid as_of format, use YYYY-MM-DD or RFC 3339",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/sub/{id}/prices": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Возвращает запланированные и прошедшие изменения цены списания",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Изменения цены подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.SubscriptionPrice"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid id",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Задает новую цену списания подписки начиная с будущего месяца",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Запланировать изменение цены",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новая цена и месяц начала ее действия",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.SubscriptionPrice"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.SubscriptionPrice"
+                        }
+                    },
+                    "400": {
+                        "description": "effective_from: required field",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "price change effective from 07/2026 already scheduled",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "effective_from must be a future month",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/sub/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Восстанавливает удаленную подписку по ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Восстановить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid id",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "subscription is not deleted",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Список вебхуков",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.Webhook"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Регистрирует адрес для событий подписок. Тело запроса подписывается HMAC-SHA256 секретом вебхука в заголовке X-Signature-256 (sha256=\u003chex\u003e). Если секрет не передан, он генерируется и возвращается только в этом ответе. Пустой список events означает все события",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Зарегистрировать вебхук",
+                "parameters": [
+                    {
+                        "description": "Вебхук",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Webhook"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Пример: {\\\"id\\\": \\\"...\\\", \\\"url\\\": \\\"https://billing.local/hooks\\\", \\\"events\\\": [], \\\"active\\\": true, \\\"secret\\\": \\\"...\\\"}",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "invalid webhook",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/dead-letters": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Возвращает события, доставка которых не удалась после всех попыток, от новых к старым",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Недоставленные события",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID вебхука (UUID)",
+                        "name": "webhook_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Количество (1-500)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid webhook_id format",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/dead-letters/{id}/retry": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Возвращает недоставленное событие в очередь с обнуленным счетчиком попыток",
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Повторить доставку",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID события",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    },
+                    "400": {
+                        "description": "invalid delivery ID",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "delivery 1 not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "delivery 1 is not a dead letter",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Получить вебхук",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID вебхука (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid UUID format",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
                     }
                 }
             },
-            "delete": {
-                "description": "Удаляет подписку по ID",
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Заменяет адрес, события и признак активности. Секрет меняется, только если передан",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "webhooks"
                 ],
-                "summary": "Удалить подписку",
+                "summary": "Обновить вебхук",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "ID подписки (UUID)",
+                        "description": "ID вебхука (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Вебхук",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.Webhook"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid UUID format",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Удаляет вебхук вместе с неотправленными событиями",
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Удалить вебхук",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID вебхука (UUID)",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -357,21 +1793,21 @@ const docTemplate = `{
                         "description": "No Content"
                     },
                     "400": {
-                        "description": "Пример: {\\\"error\\\": \\\"invalid id format\\\"}",
+                        "description": "invalid UUID format",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handler.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     },
                     "500": {
-                        "description": "Пример: {\\\"error\\\": \\\"delete operation failed\\\"}",
+                        "description": "internal server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handler.Problem"
                         }
                     }
                 }
@@ -379,16 +1815,164 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "handler.CheckResult": {
+            "type": "object",
+            "properties": {
+                "details": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "error": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "number",
+                    "example": 1.7
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "handler.Health": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/handler.CheckResult"
+                    }
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "handler.Problem": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string"
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.APIKey": {
+            "description": "API key",
+            "type": "object",
+            "required": [
+                "name",
+                "scopes"
+            ],
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 100
+                },
+                "scopes": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ExchangeRate": {
+            "type": "object",
+            "required": [
+                "currency",
+                "rate"
+            ],
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "rate": {
+                    "type": "number"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ImportReport": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "mode": {
+                    "type": "string"
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ImportRowResult"
+                    }
+                }
+            }
+        },
+        "model.ImportRowResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "line": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
         "model.Subscription": {
             "description": "Subscription information",
             "type": "object",
             "required": [
-                "monthly_cost",
                 "service_name",
                 "start_date",
                 "user_id"
             ],
             "properties": {
+                "billing_period": {
+                    "type": "string",
+                    "enum": [
+                        "weekly",
+                        "monthly",
+                        "quarterly",
+                        "yearly"
+                    ]
+                },
+                "currency": {
+                    "type": "string"
+                },
                 "end_date": {
                     "type": "string"
                 },
@@ -398,6 +1982,9 @@ const docTemplate = `{
                 "monthly_cost": {
                     "type": "integer"
                 },
+                "price": {
+                    "type": "integer"
+                },
                 "service_name": {
                     "type": "string",
                     "maxLength": 255,
@@ -410,6 +1997,63 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "model.SubscriptionPrice": {
+            "type": "object",
+            "required": [
+                "effective_from",
+                "price"
+            ],
+            "properties": {
+                "effective_from": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.Webhook": {
+            "description": "Webhook endpoint",
+            "type": "object",
+            "required": [
+                "url"
+            ],
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "secret": {
+                    "type": "string",
+                    "minLength": 16
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "Ключ API с правами subs:read, subs:write, reports:read или admin",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "JWT в формате \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
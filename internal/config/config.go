@@ -1,32 +1,289 @@
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/joho/godotenv"
-	"log"
+	"github.com/rezexell/em-test-task/pkg/ratelimit"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/fs"
 	"os"
+	"sort"
+	"strings"
+	"time"
 )
 
 type Config struct {
 	LOGLEVEL   string
 	DBHOST     string
-	DBPORT     string
+	DBPORT     int
 	DBUSER     string
 	DBPASSWORD string
 	DBNAME     string
+
+	// HTTP server. SHUTDOWNTIMEOUT is how long in-flight requests may
+	// take to finish after SIGINT or SIGTERM. The client IP is taken from
+	// X-Forwarded-For only on connections from TRUSTEDPROXIES.
+	HTTPADDR              string
+	HTTPREADTIMEOUT       time.Duration
+	HTTPREADHEADERTIMEOUT time.Duration
+	HTTPWRITETIMEOUT      time.Duration
+	HTTPIDLETIMEOUT       time.Duration
+	HTTPMAXHEADERBYTES    int
+	TRUSTEDPROXIES        []string
+	SHUTDOWNTIMEOUT       time.Duration
+
+	// NOTIFIER selects how expiry reminders are delivered: log, webhook or
+	// smtp. Reminders are disabled when it is empty.
+	NOTIFIER       string
+	NOTIFYDAYS     int
+	NOTIFYINTERVAL time.Duration
+	WEBHOOKURL     string
+	SMTPHOST       string
+	SMTPPORT       int
+	SMTPUSER       string
+	SMTPPASSWORD   string
+	SMTPFROM       string
+	SMTPTO         string
+
+	// WEBHOOKPOLLINTERVAL is how often queued webhook deliveries are sent.
+	WEBHOOKPOLLINTERVAL time.Duration
+
+	// JWTSECRET enables HS256 tokens and JWTJWKSFILE RS256 tokens. One of
+	// them is required unless AUTHDEVMODE lets callers without credentials
+	// name their user in the X-User-ID header, which is only meant for
+	// local development.
+	AUTHDEVMODE bool
+	JWTSECRET   string
+	JWTJWKSFILE string
+	JWTISSUER   string
+	JWTAUDIENCE string
+	JWTLEEWAY   time.Duration
+
+	// TRACINGEXPORTER selects where spans are sent: stdout or otlp.
+	// Tracing is disabled when it is empty. TRACINGENDPOINT is the
+	// OTLP/HTTP collector URL.
+	TRACINGEXPORTER    string
+	TRACINGENDPOINT    string
+	TRACINGSERVICENAME string
+	TRACINGSAMPLERATIO float64
+
+	// METRICSADDR is the address /metrics is served on, apart from the API
+	// since the metrics are not authenticated. They are not served when it
+	// is empty. METRICSKPIINTERVAL is how often the subscription gauges are
+	// recomputed.
+	METRICSADDR        string
+	METRICSKPIINTERVAL time.Duration
+
+	// RATELIMIT applies to every client on routes without their own limit
+	// in RATELIMITROUTES, which is keyed by method and route, such as
+	// "GET /sub/total-cost/".
+	RATELIMIT       ratelimit.Limit
+	RATELIMITROUTES map[string]ratelimit.Limit
+	// RATELIMITIP applies to every client IP before the request is
+	// authenticated, so that credential checks are limited too.
+	RATELIMITIP ratelimit.Limit
+
+	// PRINTCONFIG is set by the --print-config flag. It asks to print the
+	// configuration instead of starting the service.
+	PRINTCONFIG bool
+}
+
+// Default returns the configuration used for every setting no source
+// provides.
+func Default() *Config {
+	return &Config{
+		LOGLEVEL: "prod",
+		DBHOST:   "localhost",
+		DBPORT:   5432,
+
+		HTTPADDR:              ":3000",
+		HTTPREADTIMEOUT:       15 * time.Second,
+		HTTPREADHEADERTIMEOUT: 5 * time.Second,
+		HTTPWRITETIMEOUT:      60 * time.Second,
+		HTTPIDLETIMEOUT:       120 * time.Second,
+		HTTPMAXHEADERBYTES:    1 << 20,
+		SHUTDOWNTIMEOUT:       20 * time.Second,
+
+		NOTIFYDAYS:     7,
+		NOTIFYINTERVAL: time.Hour,
+		SMTPPORT:       25,
+
+		WEBHOOKPOLLINTERVAL: 5 * time.Second,
+
+		JWTLEEWAY: 30 * time.Second,
+
+		TRACINGSERVICENAME: "subscriptions-api",
+		TRACINGSAMPLERATIO: 1,
+
+		METRICSADDR:        "127.0.0.1:9090",
+		METRICSKPIINTERVAL: time.Minute,
+
+		RATELIMIT:       ratelimit.Limit{Rate: 10, Burst: 600}, // 600/m
+		RATELIMITROUTES: map[string]ratelimit.Limit{},
+		RATELIMITIP:     ratelimit.Limit{Rate: 20, Burst: 1200}, // 1200/m
+	}
+}
+
+// Load builds the configuration from, in increasing priority, the defaults,
+// the YAML file named by --config or CONFIG_FILE, environment variables
+// (including those in an optional .env file) and command line flags.
+// Settings use the environment variable names; in the YAML file they are
+// lower-cased, such as db_host, and flags are spelled --db-host. Every
+// invalid setting is reported in the returned error.
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+	fields := cfg.fields()
+
+	flags := flag.NewFlagSet("subscriptions-api", flag.ContinueOnError)
+	configFile := flags.String("config", "", "YAML configuration file (env CONFIG_FILE)")
+	flags.BoolVar(&cfg.PRINTCONFIG, "print-config", false, "print the configuration with secrets redacted and exit")
+	flagValues := make([]*flagValue, len(fields))
+	for i, f := range fields {
+		flagValues[i] = &flagValue{def: f.value.String()}
+		flags.Var(flagValues[i], f.flagName(), f.usage)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	var errs []error
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		errs = append(errs, fmt.Errorf(".env: %w", err))
+	}
+
+	if *configFile == "" {
+		*configFile = os.Getenv("CONFIG_FILE")
+	}
+	if *configFile != "" {
+		errs = append(errs, loadFile(*configFile, fields)...)
+	}
+
+	// A variable set to an empty value overrides the lower layers too, so
+	// that METRICS_ADDR= turns the metrics listener off.
+	for _, f := range fields {
+		if value, ok := os.LookupEnv(f.key); ok {
+			if err := f.value.Set(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", f.key, err))
+			}
+		}
+	}
+
+	for i, f := range fields {
+		if flagValues[i].raw == nil {
+			continue
+		}
+		if err := f.value.Set(*flagValues[i].raw); err != nil {
+			errs = append(errs, fmt.Errorf("--%s: %w", f.flagName(), err))
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return cfg, errors.Join(errs...)
 }
 
-func InitConfig() *Config {
-	err := godotenv.Load()
+// loadFile applies the settings of a YAML file. Rate limit routes may be
+// given as a mapping of "<method> <route>" to a limit.
+func loadFile(path string, fields []field) []error {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatalf("err loading: %v", err)
+		return []error{err}
+	}
+	var settings map[string]any
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return []error{fmt.Errorf("%s: %w", path, err)}
 	}
 
-	return &Config{
-		DBHOST:     os.Getenv("DB_HOST"),
-		DBPORT:     os.Getenv("DB_PORT"),
-		DBUSER:     os.Getenv("DB_USER"),
-		DBPASSWORD: os.Getenv("DB_PASSWORD"),
-		DBNAME:     os.Getenv("DB_NAME"),
-		LOGLEVEL:   os.Getenv("LOG_LEVEL"),
+	byKey := make(map[string]field, len(fields))
+	for _, f := range fields {
+		byKey[f.fileKey()] = f
+	}
+
+	var errs []error
+	for key, raw := range settings {
+		f, ok := byKey[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", path, key))
+			continue
+		}
+		if err := f.value.Set(fileValue(raw)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", path, key, err))
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errs
+}
+
+func fileValue(raw any) string {
+	switch v := raw.(type) {
+	case nil:
+		return ""
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	case map[string]any:
+		pairs := make([]string, 0, len(v))
+		for route, limit := range v {
+			pairs = append(pairs, fmt.Sprintf("%s=%v", route, limit))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ";")
+	default:
+		return fmt.Sprint(v)
 	}
 }
+
+// flagValue records a flag until the sources of lower priority are applied.
+type flagValue struct {
+	def string
+	raw *string
+}
+
+func (v *flagValue) String() string {
+	if v == nil {
+		return ""
+	}
+	if v.raw != nil {
+		return *v.raw
+	}
+	return v.def
+}
+
+func (v *flagValue) Set(s string) error {
+	v.raw = &s
+	return nil
+}
+
+// Print writes the configuration as a YAML file accepted by Load with the
+// secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range c.fields() {
+		value := f.value.String()
+		node := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+		if f.secret && value != "" {
+			node.Value, node.Style = redacted, yaml.DoubleQuotedStyle
+		}
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: f.fileKey()}, node)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+const redacted = "[REDACTED]"
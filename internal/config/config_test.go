@@ -0,0 +1,300 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rezexell/em-test-task/pkg/ratelimit"
+)
+
+// unsetenv removes key from the environment until the test ends.
+func unsetenv(t *testing.T, key string) {
+	t.Helper()
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+// loadEnv removes every setting from the environment and sets the required
+// ones, then env on top.
+func loadEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	unsetenv(t, "CONFIG_FILE")
+	for _, f := range Default().fields() {
+		unsetenv(t, f.key)
+	}
+	t.Setenv("DB_USER", "app")
+	t.Setenv("DB_NAME", "subscriptions")
+	t.Setenv("AUTH_DEV_MODE", "true")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+}
+
+func writeYAML(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// baseline is the configuration loaded from the settings loadEnv requires.
+func baseline() *Config {
+	cfg := Default()
+	cfg.DBUSER, cfg.DBNAME, cfg.AUTHDEVMODE = "app", "subscriptions", true
+	return cfg
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		// fileFromEnv names the YAML file in CONFIG_FILE instead of --config.
+		fileFromEnv bool
+		env         map[string]string
+		args        []string
+		want        func(cfg *Config)
+	}{
+		{
+			name: "defaults",
+			want: func(cfg *Config) {},
+		},
+		{
+			name: "yaml overrides defaults",
+			yaml: "db_host: yaml-host\ndb_port: 6432\nhttp_read_timeout: 30s\n",
+			want: func(cfg *Config) {
+				cfg.DBHOST, cfg.DBPORT, cfg.HTTPREADTIMEOUT = "yaml-host", 6432, 30*time.Second
+			},
+		},
+		{
+			name:        "yaml file from CONFIG_FILE",
+			yaml:        "db_host: yaml-host\n",
+			fileFromEnv: true,
+			want:        func(cfg *Config) { cfg.DBHOST = "yaml-host" },
+		},
+		{
+			name: "env overrides yaml",
+			yaml: "db_host: yaml-host\ndb_port: 6432\n",
+			env:  map[string]string{"DB_HOST": "env-host"},
+			want: func(cfg *Config) { cfg.DBHOST, cfg.DBPORT = "env-host", 6432 },
+		},
+		{
+			name: "flags override env and yaml",
+			yaml: "db_host: yaml-host\ndb_port: 6432\nlog_level: dev\n",
+			env:  map[string]string{"DB_HOST": "env-host", "DB_PORT": "7432"},
+			args: []string{"--db-host", "flag-host"},
+			want: func(cfg *Config) { cfg.DBHOST, cfg.DBPORT, cfg.LOGLEVEL = "flag-host", 7432, "dev" },
+		},
+		{
+			name: "flag set to the default still overrides env",
+			env:  map[string]string{"LOG_LEVEL": "dev"},
+			args: []string{"--log-level=prod"},
+			want: func(cfg *Config) {},
+		},
+		{
+			name: "yaml list of trusted proxies",
+			yaml: "trusted_proxies:\n  - 10.0.0.0/8\n  - 192.168.1.1\n",
+			want: func(cfg *Config) { cfg.TRUSTEDPROXIES = []string{"10.0.0.0/8", "192.168.1.1"} },
+		},
+		{
+			name: "comma separated trusted proxies in env",
+			env:  map[string]string{"TRUSTED_PROXIES": "10.0.0.1, ,fd00::/8"},
+			want: func(cfg *Config) { cfg.TRUSTEDPROXIES = []string{"10.0.0.1", "fd00::/8"} },
+		},
+		{
+			name: "trusted proxies flag replaces the yaml list",
+			yaml: "trusted_proxies: [10.0.0.0/8, 172.16.0.0/12]\n",
+			args: []string{"--trusted-proxies", "127.0.0.1"},
+			want: func(cfg *Config) { cfg.TRUSTEDPROXIES = []string{"127.0.0.1"} },
+		},
+		{
+			name: "yaml rate limits",
+			yaml: "rate_limit: 120/m\nrate_limit_ip: 60/m:10\nrate_limit_routes:\n  GET /sub/total-cost/: 10/m\n  POST  /sub/import: 1/m:2\n",
+			want: func(cfg *Config) {
+				cfg.RATELIMIT = ratelimit.Limit{Rate: 2, Burst: 120}
+				cfg.RATELIMITIP = ratelimit.Limit{Rate: 1, Burst: 10}
+				cfg.RATELIMITROUTES = map[string]ratelimit.Limit{
+					"GET /sub/total-cost/": {Rate: 10.0 / 60, Burst: 10},
+					"POST /sub/import":     {Rate: 1.0 / 60, Burst: 2},
+				}
+			},
+		},
+		{
+			name: "RATE_LIMIT_IP off in env overrides yaml",
+			yaml: "rate_limit_ip: 60/m\n",
+			env:  map[string]string{"RATE_LIMIT_IP": "off"},
+			want: func(cfg *Config) { cfg.RATELIMITIP = ratelimit.Limit{} },
+		},
+		{
+			name: "RATE_LIMIT_IP flag",
+			env:  map[string]string{"RATE_LIMIT_IP": "off"},
+			args: []string{"--rate-limit-ip", "10/s"},
+			want: func(cfg *Config) { cfg.RATELIMITIP = ratelimit.Limit{Rate: 10, Burst: 10} },
+		},
+		{
+			name: "empty METRICS_ADDR in env disables metrics",
+			yaml: "metrics_addr: 127.0.0.1:9191\n",
+			env:  map[string]string{"METRICS_ADDR": ""},
+			want: func(cfg *Config) { cfg.METRICSADDR = "" },
+		},
+		{
+			name: "empty env value overrides yaml",
+			yaml: "trusted_proxies: [10.0.0.0/8]\nrate_limit_ip: 60/m\n",
+			env:  map[string]string{"TRUSTED_PROXIES": "", "RATE_LIMIT_IP": ""},
+			want: func(cfg *Config) { cfg.TRUSTEDPROXIES, cfg.RATELIMITIP = nil, ratelimit.Limit{} },
+		},
+		{
+			name: "empty METRICS_ADDR in yaml disables metrics",
+			yaml: "metrics_addr:\n",
+			want: func(cfg *Config) { cfg.METRICSADDR = "" },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loadEnv(t, tt.env)
+			args := tt.args
+			if tt.yaml != "" {
+				path := writeYAML(t, tt.yaml)
+				if tt.fileFromEnv {
+					t.Setenv("CONFIG_FILE", path)
+				} else {
+					args = append([]string{"--config", path}, args...)
+				}
+			}
+
+			got, err := Load(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := baseline()
+			tt.want(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got  %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		args []string
+		// want are the messages the error must contain.
+		want []string
+	}{
+		{
+			name: "unknown yaml setting",
+			yaml: "db_hots: localhost\n",
+			want: []string{`unknown setting "db_hots"`},
+		},
+		{
+			name: "malformed yaml",
+			yaml: "db_host: [\n",
+			want: []string{"config.yaml"},
+		},
+		{
+			name: "missing yaml file",
+			args: []string{"--config", filepath.Join(os.TempDir(), "no-such-config.yaml")},
+			want: []string{"no-such-config.yaml"},
+		},
+		{
+			name: "invalid yaml value",
+			yaml: "db_port: five\n",
+			want: []string{`db_port: "five" is not an integer`},
+		},
+		{
+			name: "invalid env value",
+			env:  map[string]string{"HTTP_READ_TIMEOUT": "soon"},
+			want: []string{"HTTP_READ_TIMEOUT:"},
+		},
+		{
+			name: "invalid flag value",
+			args: []string{"--db-port", "x"},
+			want: []string{`--db-port: "x" is not an integer`},
+		},
+		{
+			name: "unexpected arguments",
+			args: []string{"serve"},
+			want: []string{"unexpected arguments: serve"},
+		},
+		{
+			name: "every invalid setting is reported",
+			env:  map[string]string{"LOG_LEVEL": "debug", "DB_PORT": "70000", "SHUTDOWN_TIMEOUT": "0s"},
+			want: []string{"LOG_LEVEL:", "DB_PORT: 70000 is not a valid port", "SHUTDOWN_TIMEOUT: must be positive"},
+		},
+		{
+			name: "no authentication",
+			env:  map[string]string{"AUTH_DEV_MODE": "false"},
+			want: []string{"JWT_SECRET or JWT_JWKS_FILE is required"},
+		},
+		{
+			name: "missing smtp settings",
+			env:  map[string]string{"NOTIFIER": "smtp"},
+			want: []string{"SMTP_HOST is required", "SMTP_FROM is required", "SMTP_TO is required"},
+		},
+		{
+			name: "trusted proxy that is not an IP or CIDR",
+			env:  map[string]string{"TRUSTED_PROXIES": "10.0.0.1,proxy.local,10.0.0.0/33"},
+			want: []string{
+				`TRUSTED_PROXIES: "proxy.local" is not an IP or CIDR`,
+				`TRUSTED_PROXIES: "10.0.0.0/33" is not an IP or CIDR`,
+			},
+		},
+		{
+			name: "invalid trusted proxy in a yaml list",
+			yaml: "trusted_proxies: [10.0.0.1, 10.0.0.256]\n",
+			want: []string{`TRUSTED_PROXIES: "10.0.0.256" is not an IP or CIDR`},
+		},
+		{
+			name: "invalid RATE_LIMIT_IP",
+			env:  map[string]string{"RATE_LIMIT_IP": "100/d"},
+			want: []string{"RATE_LIMIT_IP: invalid rate limit"},
+		},
+		{
+			name: "invalid rate_limit_ip in yaml",
+			yaml: "rate_limit_ip: 0/m\n",
+			want: []string{"rate_limit_ip: invalid rate limit"},
+		},
+		{
+			name: "empty env value of a required setting",
+			env:  map[string]string{"DB_NAME": "", "DB_PORT": ""},
+			want: []string{"DB_NAME is required", `DB_PORT: "" is not an integer`},
+		},
+		{
+			name: "METRICS_ADDR that is not host:port",
+			env:  map[string]string{"METRICS_ADDR": "9090"},
+			want: []string{`METRICS_ADDR: "9090" is not a host:port address`},
+		},
+		{
+			name: "METRICS_ADDR shared with the API",
+			env:  map[string]string{"METRICS_ADDR": ":3000"},
+			want: []string{"METRICS_ADDR: must differ from HTTP_ADDR"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loadEnv(t, tt.env)
+			args := tt.args
+			if tt.yaml != "" {
+				args = append([]string{"--config", writeYAML(t, tt.yaml)}, args...)
+			}
+
+			_, err := Load(args)
+			if err == nil {
+				t.Fatal("Load succeeded")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error does not contain %q:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
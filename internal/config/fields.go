@@ -0,0 +1,213 @@
+package config
+
+import (
+	"fmt"
+	"github.com/rezexell/em-test-task/pkg/ratelimit"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field is a setting named after its environment variable.
+type field struct {
+	key    string
+	value  value
+	usage  string
+	secret bool
+}
+
+func (f field) fileKey() string {
+	return strings.ToLower(f.key)
+}
+
+func (f field) flagName() string {
+	return strings.ReplaceAll(f.fileKey(), "_", "-")
+}
+
+// value parses a setting into a Config field and formats it back.
+type value interface {
+	Set(string) error
+	String() string
+}
+
+func (c *Config) fields() []field {
+	return []field{
+		{key: "LOG_LEVEL", value: stringValue(&c.LOGLEVEL), usage: "logging mode: dev or prod"},
+		{key: "DB_HOST", value: stringValue(&c.DBHOST), usage: "PostgreSQL host"},
+		{key: "DB_PORT", value: intValue(&c.DBPORT), usage: "PostgreSQL port"},
+		{key: "DB_USER", value: stringValue(&c.DBUSER), usage: "PostgreSQL user"},
+		{key: "DB_PASSWORD", value: stringValue(&c.DBPASSWORD), usage: "PostgreSQL password", secret: true},
+		{key: "DB_NAME", value: stringValue(&c.DBNAME), usage: "PostgreSQL database"},
+
+		{key: "HTTP_ADDR", value: stringValue(&c.HTTPADDR), usage: "address the HTTP server listens on"},
+		{key: "HTTP_READ_TIMEOUT", value: durationValue(&c.HTTPREADTIMEOUT), usage: "time to read a whole request"},
+		{key: "HTTP_READ_HEADER_TIMEOUT", value: durationValue(&c.HTTPREADHEADERTIMEOUT), usage: "time to read request headers"},
+		{key: "HTTP_WRITE_TIMEOUT", value: durationValue(&c.HTTPWRITETIMEOUT), usage: "time to write a response"},
+		{key: "HTTP_IDLE_TIMEOUT", value: durationValue(&c.HTTPIDLETIMEOUT), usage: "keep-alive connection idle time"},
+		{key: "HTTP_MAX_HEADER_BYTES", value: intValue(&c.HTTPMAXHEADERBYTES), usage: "maximum size of request headers"},
+		{key: "TRUSTED_PROXIES", value: listValue(&c.TRUSTEDPROXIES), usage: "comma separated IPs or CIDRs of proxies whose X-Forwarded-For is trusted"},
+		{key: "SHUTDOWN_TIMEOUT", value: durationValue(&c.SHUTDOWNTIMEOUT), usage: "time in-flight requests may take after a signal"},
+
+		{key: "NOTIFIER", value: stringValue(&c.NOTIFIER), usage: "expiry reminder channel: log, webhook or smtp"},
+		{key: "NOTIFY_DAYS", value: intValue(&c.NOTIFYDAYS), usage: "days before the end date to send a reminder"},
+		{key: "NOTIFY_INTERVAL", value: durationValue(&c.NOTIFYINTERVAL), usage: "how often expiring subscriptions are checked"},
+		{key: "NOTIFY_WEBHOOK_URL", value: stringValue(&c.WEBHOOKURL), usage: "URL the webhook notifier posts to"},
+		{key: "SMTP_HOST", value: stringValue(&c.SMTPHOST), usage: "SMTP server host"},
+		{key: "SMTP_PORT", value: intValue(&c.SMTPPORT), usage: "SMTP server port"},
+		{key: "SMTP_USER", value: stringValue(&c.SMTPUSER), usage: "SMTP user"},
+		{key: "SMTP_PASSWORD", value: stringValue(&c.SMTPPASSWORD), usage: "SMTP password", secret: true},
+		{key: "SMTP_FROM", value: stringValue(&c.SMTPFROM), usage: "sender of reminder emails"},
+		{key: "SMTP_TO", value: stringValue(&c.SMTPTO), usage: "comma separated recipients of reminder emails"},
+
+		{key: "WEBHOOK_POLL_INTERVAL", value: durationValue(&c.WEBHOOKPOLLINTERVAL), usage: "how often queued webhook deliveries are sent"},
+
+		{key: "AUTH_DEV_MODE", value: boolValue(&c.AUTHDEVMODE), usage: "trust the X-User-ID header of requests without credentials"},
+		{key: "JWT_SECRET", value: stringValue(&c.JWTSECRET), usage: "HS256 signing secret", secret: true},
+		{key: "JWT_JWKS_FILE", value: stringValue(&c.JWTJWKSFILE), usage: "JWKS file with RS256 keys"},
+		{key: "JWT_ISSUER", value: stringValue(&c.JWTISSUER), usage: "required token issuer"},
+		{key: "JWT_AUDIENCE", value: stringValue(&c.JWTAUDIENCE), usage: "required token audience"},
+		{key: "JWT_LEEWAY", value: durationValue(&c.JWTLEEWAY), usage: "allowed clock skew for token times"},
+
+		{key: "TRACING_EXPORTER", value: stringValue(&c.TRACINGEXPORTER), usage: "span exporter: stdout or otlp"},
+		{key: "TRACING_OTLP_ENDPOINT", value: stringValue(&c.TRACINGENDPOINT), usage: "OTLP/HTTP collector URL"},
+		{key: "TRACING_SERVICE_NAME", value: stringValue(&c.TRACINGSERVICENAME), usage: "service name reported in spans"},
+		{key: "TRACING_SAMPLE_RATIO", value: floatValue(&c.TRACINGSAMPLERATIO), usage: "fraction of new traces to sample"},
+
+		{key: "METRICS_ADDR", value: stringValue(&c.METRICSADDR), usage: "address /metrics is served on, empty to not serve it"},
+		{key: "METRICS_KPI_INTERVAL", value: durationValue(&c.METRICSKPIINTERVAL), usage: "how often subscription gauges are recomputed"},
+
+		{key: "RATE_LIMIT", value: limitValue{&c.RATELIMIT}, usage: `default rate limit, such as "600/m" or "off"`},
+		{key: "RATE_LIMIT_ROUTES", value: routeLimitsValue{&c.RATELIMITROUTES}, usage: `per route rate limits, such as "GET /sub/total-cost/=10/m"`},
+		{key: "RATE_LIMIT_IP", value: limitValue{&c.RATELIMITIP}, usage: `rate limit per client IP applied before authentication, such as "1200/m" or "off"`},
+	}
+}
+
+// parsedValue is a value parsed and formatted by a pair of functions.
+type parsedValue[T any] struct {
+	p      *T
+	parse  func(string) (T, error)
+	format func(T) string
+}
+
+func (v parsedValue[T]) Set(s string) error {
+	parsed, err := v.parse(strings.TrimSpace(s))
+	if err != nil {
+		return err
+	}
+	*v.p = parsed
+	return nil
+}
+
+func (v parsedValue[T]) String() string {
+	return v.format(*v.p)
+}
+
+func stringValue(p *string) value {
+	return parsedValue[string]{p, func(s string) (string, error) { return s, nil }, func(s string) string { return s }}
+}
+
+// listValue parses comma separated items, dropping empty ones.
+func listValue(p *[]string) value {
+	return parsedValue[[]string]{p, func(s string) ([]string, error) {
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items, nil
+	}, func(items []string) string { return strings.Join(items, ",") }}
+}
+
+func intValue(p *int) value {
+	return parsedValue[int]{p, func(s string) (int, error) {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not an integer", s)
+		}
+		return n, nil
+	}, strconv.Itoa}
+}
+
+func boolValue(p *bool) value {
+	return parsedValue[bool]{p, func(s string) (bool, error) {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return false, fmt.Errorf("%q is not true or false", s)
+		}
+		return b, nil
+	}, strconv.FormatBool}
+}
+
+func floatValue(p *float64) value {
+	return parsedValue[float64]{p, func(s string) (float64, error) {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", s)
+		}
+		return f, nil
+	}, func(f float64) string { return strconv.FormatFloat(f, 'g', -1, 64) }}
+}
+
+func durationValue(p *time.Duration) value {
+	return parsedValue[time.Duration]{p, func(s string) (time.Duration, error) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a duration such as 30s or 5m", s)
+		}
+		return d, nil
+	}, time.Duration.String}
+}
+
+type limitValue struct {
+	p *ratelimit.Limit
+}
+
+func (v limitValue) Set(s string) error {
+	limit, err := ratelimit.ParseLimit(s)
+	if err != nil {
+		return err
+	}
+	*v.p = limit
+	return nil
+}
+
+func (v limitValue) String() string {
+	return v.p.String()
+}
+
+// routeLimitsValue parses "<method> <route>=<limit>" pairs separated by
+// semicolons, such as "GET /sub/total-cost/=10/m;POST /sub/import=1/m:2".
+type routeLimitsValue struct {
+	p *map[string]ratelimit.Limit
+}
+
+func (v routeLimitsValue) Set(s string) error {
+	limits := make(map[string]ratelimit.Limit)
+	for _, pair := range strings.Split(s, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		route, spec, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("%q is not <method> <route>=<limit>", pair)
+		}
+		limit, err := ratelimit.ParseLimit(spec)
+		if err != nil {
+			return err
+		}
+		limits[strings.Join(strings.Fields(route), " ")] = limit
+	}
+	*v.p = limits
+	return nil
+}
+
+func (v routeLimitsValue) String() string {
+	pairs := make([]string, 0, len(*v.p))
+	for route, limit := range *v.p {
+		pairs = append(pairs, route+"="+limit.String())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
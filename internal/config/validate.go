@@ -0,0 +1,92 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Validate reports every setting that is missing or out of range.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		check(slices.Contains(allowed, value), "%s: %q is not one of %q", key, value, allowed)
+	}
+	positive := func(key string, d time.Duration) {
+		check(d > 0, "%s: must be positive, got %s", key, d)
+	}
+	notNegative := func(key string, d time.Duration) {
+		check(d >= 0, "%s: cannot be negative, got %s", key, d)
+	}
+	validURL := func(key, value string) {
+		u, err := url.Parse(value)
+		check(err == nil && u.Scheme != "" && u.Host != "", "%s: %q is not an absolute URL", key, value)
+	}
+
+	oneOf("LOG_LEVEL", c.LOGLEVEL, "dev", "prod")
+
+	check(c.DBHOST != "", "DB_HOST is required")
+	check(c.DBPORT > 0 && c.DBPORT <= 65535, "DB_PORT: %d is not a valid port", c.DBPORT)
+	check(c.DBUSER != "", "DB_USER is required")
+	check(c.DBNAME != "", "DB_NAME is required")
+
+	_, _, err := net.SplitHostPort(c.HTTPADDR)
+	check(err == nil, "HTTP_ADDR: %q is not a host:port address", c.HTTPADDR)
+	notNegative("HTTP_READ_TIMEOUT", c.HTTPREADTIMEOUT)
+	notNegative("HTTP_READ_HEADER_TIMEOUT", c.HTTPREADHEADERTIMEOUT)
+	notNegative("HTTP_WRITE_TIMEOUT", c.HTTPWRITETIMEOUT)
+	notNegative("HTTP_IDLE_TIMEOUT", c.HTTPIDLETIMEOUT)
+	check(c.HTTPMAXHEADERBYTES > 0, "HTTP_MAX_HEADER_BYTES: must be positive, got %d", c.HTTPMAXHEADERBYTES)
+	for _, proxy := range c.TRUSTEDPROXIES {
+		_, _, err := net.ParseCIDR(proxy)
+		check(err == nil || net.ParseIP(proxy) != nil, "TRUSTED_PROXIES: %q is not an IP or CIDR", proxy)
+	}
+	positive("SHUTDOWN_TIMEOUT", c.SHUTDOWNTIMEOUT)
+
+	oneOf("NOTIFIER", c.NOTIFIER, "", "log", "webhook", "smtp")
+	if c.NOTIFIER != "" {
+		check(c.NOTIFYDAYS > 0, "NOTIFY_DAYS: must be positive, got %d", c.NOTIFYDAYS)
+		positive("NOTIFY_INTERVAL", c.NOTIFYINTERVAL)
+	}
+	switch c.NOTIFIER {
+	case "webhook":
+		check(c.WEBHOOKURL != "", "NOTIFY_WEBHOOK_URL is required for the webhook notifier")
+		if c.WEBHOOKURL != "" {
+			validURL("NOTIFY_WEBHOOK_URL", c.WEBHOOKURL)
+		}
+	case "smtp":
+		check(c.SMTPHOST != "", "SMTP_HOST is required for the smtp notifier")
+		check(c.SMTPPORT > 0 && c.SMTPPORT <= 65535, "SMTP_PORT: %d is not a valid port", c.SMTPPORT)
+		check(c.SMTPFROM != "", "SMTP_FROM is required for the smtp notifier")
+		check(c.SMTPTO != "", "SMTP_TO is required for the smtp notifier")
+	}
+
+	positive("WEBHOOK_POLL_INTERVAL", c.WEBHOOKPOLLINTERVAL)
+
+	check(c.JWTSECRET != "" || c.JWTJWKSFILE != "" || c.AUTHDEVMODE, "JWT_SECRET or JWT_JWKS_FILE is required unless AUTH_DEV_MODE is true")
+	notNegative("JWT_LEEWAY", c.JWTLEEWAY)
+
+	oneOf("TRACING_EXPORTER", c.TRACINGEXPORTER, "", "stdout", "otlp")
+	if c.TRACINGENDPOINT != "" {
+		validURL("TRACING_OTLP_ENDPOINT", c.TRACINGENDPOINT)
+	}
+	check(c.TRACINGEXPORTER == "" || c.TRACINGSERVICENAME != "", "TRACING_SERVICE_NAME is required when tracing is enabled")
+	check(c.TRACINGSAMPLERATIO >= 0 && c.TRACINGSAMPLERATIO <= 1, "TRACING_SAMPLE_RATIO: must be between 0 and 1, got %g", c.TRACINGSAMPLERATIO)
+
+	if c.METRICSADDR != "" {
+		_, _, err := net.SplitHostPort(c.METRICSADDR)
+		check(err == nil, "METRICS_ADDR: %q is not a host:port address", c.METRICSADDR)
+		check(c.METRICSADDR != c.HTTPADDR, "METRICS_ADDR: must differ from HTTP_ADDR, metrics are not authenticated")
+	}
+	positive("METRICS_KPI_INTERVAL", c.METRICSKPIINTERVAL)
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/rezexell/em-test-task/internal/model"
+)
+
+// SetExchangeRates
+// @Summary Загрузить курсы валют
+// @Description Создает или обновляет курсы валют к рублю. Принимает JSON-массив или CSV (currency,rate) с Content-Type text/csv
+// @Tags admin
+// @Accept json
+// @Accept text/csv
+// @Produce json
+// @Param input body []model.ExchangeRate true "Курсы валют"
+// @Success 200 {array} model.ExchangeRate
+// @Failure 400 {object} handler.Problem "unsupported currency XYZ"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/exchange-rates [put]
+func (h *Handler) SetExchangeRates(c *gin.Context) {
+	const fn = "handler.SetExchangeRates"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	var (
+		rates []*model.ExchangeRate
+		err   error
+	)
+	if c.ContentType() == "text/csv" {
+		rates, err = parseRatesCSV(c.Request.Body)
+	} else {
+		err = c.ShouldBindJSON(&rates)
+	}
+	if err != nil {
+		c.Error(model.Validationf("%v", err))
+		return
+	}
+
+	if err := h.service.SetExchangeRates(c.Request.Context(), rates); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rates)
+	return
+}
+
+// GetExchangeRates
+// @Summary Курсы валют
+// @Description Возвращает загруженные курсы валют к рублю
+// @Tags admin
+// @Produce json
+// @Success 200 {array} model.ExchangeRate
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/exchange-rates [get]
+func (h *Handler) GetExchangeRates(c *gin.Context) {
+	const fn = "handler.GetExchangeRates"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	rates, err := h.service.ListExchangeRates(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if rates == nil {
+		rates = []*model.ExchangeRate{}
+	}
+
+	c.JSON(http.StatusOK, rates)
+	return
+}
+
+// parseRatesCSV reads "currency,rate" records. A leading header row is skipped.
+func parseRatesCSV(r io.Reader) ([]*model.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	var rates []*model.ExchangeRate
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(record[0], "currency") {
+			continue
+		}
+
+		rate, err := strconv.ParseFloat(record[1], 64)
+		if err != nil {
+			return nil, model.Validationf("line %d: invalid rate %q", line, record[1])
+		}
+		rates = append(rates, &model.ExchangeRate{Currency: record[0], Rate: rate})
+	}
+
+	if err := binding.Validator.ValidateStruct(rates); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+)
+
+// CreateAPIKey
+// @Summary Создать ключ API
+// @Description Создает ключ для межсервисных запросов с заданными правами (subs:read, subs:write, reports:read, admin). Ключ передается в заголовке X-API-Key и возвращается только в этом ответе. Ключ с user_id действует от имени пользователя, без него - для всех пользователей
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param input body model.APIKey true "Ключ API"
+// @Success 201 {object} map[string]interface{} "Пример: {\"id\": \"...\", \"name\": \"billing-export\", \"prefix\": \"sk_AbCdEfGh\", \"scopes\": [\"subs:read\"], \"key\": \"sk_...\"}"
+// @Failure 400 {object} handler.Problem "invalid api key"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/api-keys [post]
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	const fn = "handler.CreateAPIKey"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	var key model.APIKey
+	if err := c.ShouldBindJSON(&key); err != nil {
+		c.Error(model.Validationf("%v", err))
+		return
+	}
+
+	raw, err := h.service.CreateAPIKey(c.Request.Context(), &key)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := key.ToResponse()
+	response["key"] = raw
+	c.JSON(http.StatusCreated, response)
+	return
+}
+
+// GetAPIKeys
+// @Summary Список ключей API
+// @Description Возвращает все ключи, включая отозванные, с временем последнего использования
+// @Tags admin
+// @Produce json
+// @Success 200 {array} model.APIKey
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/api-keys [get]
+func (h *Handler) GetAPIKeys(c *gin.Context) {
+	const fn = "handler.GetAPIKeys"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	keys, err := h.service.ListAPIKeys(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := make([]gin.H, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, key.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, response)
+	return
+}
+
+// RevokeAPIKey
+// @Summary Отозвать ключ API
+// @Tags admin
+// @Param id path string true "ID ключа (UUID)"
+// @Success 204
+// @Failure 400 {object} handler.Problem "invalid UUID format"
+// @Failure 404 {object} handler.Problem "api key not found"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /admin/api-keys/{id} [delete]
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	const fn = "handler.RevokeAPIKey"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(model.Validationf("invalid UUID format"))
+		return
+	}
+
+	if err := h.service.RevokeAPIKey(c.Request.Context(), id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+	return
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/pkg/jwt"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+	userIDHeader = "X-User-ID"
+)
+
+// authMiddleware authenticates the request with the API key in X-API-Key or
+// the bearer JWT and stores the caller in the request context. For JWTs the
+// user is taken from the "user_id" claim, or from "sub" when it is a UUID,
+// and the roles from the "roles" claim. Requests without credentials are
+// rejected unless development authentication is enabled.
+func (h *Handler) authMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var (
+			principal *model.Principal
+			err       error
+		)
+		switch {
+		case c.GetHeader(apiKeyHeader) != "":
+			var key *model.APIKey
+			if key, err = h.service.AuthenticateAPIKey(c.Request.Context(), c.GetHeader(apiKeyHeader)); err == nil {
+				principal = key.Principal()
+			}
+		case c.GetHeader("Authorization") == "" && h.devAuth:
+			principal, err = devPrincipal(c.GetHeader(userIDHeader))
+		case h.verifier == nil:
+			err = model.Unauthorizedf("missing api key")
+		default:
+			principal, err = authenticate(h.verifier, c.GetHeader("Authorization"))
+		}
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="api"`)
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		ctx := model.WithPrincipal(c.Request.Context(), principal)
+		c.Request = c.Request.WithContext(model.WithActor(ctx, principal.Actor()))
+		c.Next()
+	}
+}
+
+// devPrincipal trusts the caller to name its user. It never grants more
+// than the user role.
+func devPrincipal(rawUserID string) (*model.Principal, error) {
+	if rawUserID == "" {
+		return nil, model.Unauthorizedf("missing credentials or %s header", userIDHeader)
+	}
+	userID, err := uuid.Parse(rawUserID)
+	if err != nil {
+		return nil, model.Unauthorizedf("%s is not a UUID", userIDHeader)
+	}
+	return &model.Principal{Subject: "dev:" + userID.String(), UserID: &userID, Roles: []string{model.RoleUser}}, nil
+}
+
+func authenticate(verifier *jwt.Verifier, authorization string) (*model.Principal, error) {
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, model.Unauthorizedf("missing bearer token")
+	}
+
+	claims, err := verifier.Verify(strings.TrimSpace(token))
+	if err != nil {
+		return nil, model.Unauthorizedf("invalid token: %v", err)
+	}
+
+	principal := &model.Principal{
+		Subject: claims.String("sub"),
+		Roles:   claims.Strings("roles"),
+	}
+	if raw := claims.String("user_id"); raw != "" {
+		userID, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, model.Unauthorizedf("invalid token: user_id is not a UUID")
+		}
+		principal.UserID = &userID
+	} else if userID, err := uuid.Parse(principal.Subject); err == nil {
+		principal.UserID = &userID
+	}
+
+	return principal, nil
+}
+
+// authorize rejects callers whose roles do not allow action, or whose API
+// key lacks the scope of the same name, with the reason in the problem
+// detail.
+func authorize(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := model.PrincipalFromContext(c.Request.Context())
+		switch {
+		case !ok:
+			c.Error(model.Unauthorizedf("authentication required"))
+		case !principal.Allows(action):
+			c.Error(model.Forbiddenf("role %s is not allowed to %s", principal.RoleName(), action))
+		case !principal.HasScope(action):
+			c.Error(model.Forbiddenf("api key lacks the %s scope", action))
+		default:
+			c.Next()
+			return
+		}
+		c.Abort()
+	}
+}
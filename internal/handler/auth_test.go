@@ -0,0 +1,291 @@
+package handler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/internal/service"
+	"github.com/rezexell/em-test-task/pkg/jwt"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	model.RegisterCustomBindings()
+}
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// principalRouter serves the authenticated principal of requests allowed to
+// perform action.
+func principalRouter(h *Handler, action string) *gin.Engine {
+	router := gin.New()
+	router.Use(errorHandler(h.logger))
+	router.GET("/", h.authMiddleware(), authorize(action), func(c *gin.Context) {
+		principal, _ := model.PrincipalFromContext(c.Request.Context())
+		c.JSON(http.StatusOK, principal)
+	})
+	return router
+}
+
+func serve(router http.Handler, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthMiddlewareFailsClosedWithoutVerifier(t *testing.T) {
+	router := principalRouter(&Handler{logger: discardLogger}, model.ScopeSubsRead)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+	}{
+		{"no credentials", nil},
+		{"user header without dev mode", map[string]string{userIDHeader: uuid.NewString()}},
+		{"bearer token without verifier", map[string]string{"Authorization": "Bearer token"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(router, tt.headers)
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want 401: %s", rec.Code, rec.Body)
+			}
+			if rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("WWW-Authenticate header is missing")
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareDevAuth(t *testing.T) {
+	h := &Handler{devAuth: true, logger: discardLogger}
+	userID := uuid.New()
+
+	tests := []struct {
+		name    string
+		action  string
+		headers map[string]string
+		status  int
+	}{
+		{"user header", model.ScopeSubsWrite, map[string]string{userIDHeader: userID.String()}, http.StatusOK},
+		{"missing user header", model.ScopeSubsRead, nil, http.StatusUnauthorized},
+		{"invalid user header", model.ScopeSubsRead, map[string]string{userIDHeader: "admin"}, http.StatusUnauthorized},
+		{"admin action", model.ScopeAdmin, map[string]string{userIDHeader: userID.String()}, http.StatusForbidden},
+		{"bearer token is not skipped", model.ScopeSubsRead, map[string]string{"Authorization": "Bearer token", userIDHeader: userID.String()}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(principalRouter(h, tt.action), tt.headers)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var principal model.Principal
+			if err := json.Unmarshal(rec.Body.Bytes(), &principal); err != nil {
+				t.Fatal(err)
+			}
+			if principal.UserID == nil || *principal.UserID != userID {
+				t.Errorf("UserID = %v, want %s", principal.UserID, userID)
+			}
+			if len(principal.Roles) != 1 || principal.Roles[0] != model.RoleUser {
+				t.Errorf("Roles = %v, want [user]", principal.Roles)
+			}
+		})
+	}
+}
+
+var jwtSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// bearer signs claims with jwtSecret, adding an expiry unless given.
+func bearer(claims map[string]any) string {
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		panic(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, jwtSecret)
+	mac.Write([]byte(signed))
+	return "Bearer " + signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuthMiddlewareJWTClaims(t *testing.T) {
+	verifier, err := jwt.NewVerifier(jwt.Config{HMACSecret: jwtSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{verifier: verifier, logger: discardLogger}
+	userID, otherID := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name          string
+		authorization string
+		action        string
+		status        int
+		want          model.Principal
+	}{
+		{
+			name:          "user from a UUID sub",
+			authorization: bearer(map[string]any{"sub": userID.String()}),
+			action:        model.ScopeSubsWrite,
+			status:        http.StatusOK,
+			want:          model.Principal{Subject: userID.String(), UserID: &userID},
+		},
+		{
+			name:          "user_id takes precedence over sub",
+			authorization: bearer(map[string]any{"sub": otherID.String(), "user_id": userID.String(), "roles": []string{"user"}}),
+			action:        model.ScopeSubsRead,
+			status:        http.StatusOK,
+			want:          model.Principal{Subject: otherID.String(), UserID: &userID, Roles: []string{"user"}},
+		},
+		{
+			name:          "service without a user",
+			authorization: bearer(map[string]any{"sub": "billing-service", "roles": []string{"analyst"}}),
+			action:        model.ScopeReportsRead,
+			status:        http.StatusOK,
+			want:          model.Principal{Subject: "billing-service", Roles: []string{"analyst"}},
+		},
+		{
+			name:          "single role as a string",
+			authorization: bearer(map[string]any{"sub": "ops", "roles": "admin"}),
+			action:        model.ScopeAdmin,
+			status:        http.StatusOK,
+			want:          model.Principal{Subject: "ops", Roles: []string{"admin"}},
+		},
+		{
+			name:          "lower case scheme",
+			authorization: strings.Replace(bearer(map[string]any{"sub": userID.String()}), "Bearer", "bearer", 1),
+			action:        model.ScopeSubsRead,
+			status:        http.StatusOK,
+			want:          model.Principal{Subject: userID.String(), UserID: &userID},
+		},
+		{
+			name:          "user without roles is not an admin",
+			authorization: bearer(map[string]any{"sub": userID.String()}),
+			action:        model.ScopeAdmin,
+			status:        http.StatusForbidden,
+		},
+		{
+			name:          "user_id that is not a UUID",
+			authorization: bearer(map[string]any{"sub": "ops", "user_id": "ops"}),
+			action:        model.ScopeSubsRead,
+			status:        http.StatusUnauthorized,
+		},
+		{
+			name:          "expired token",
+			authorization: bearer(map[string]any{"sub": userID.String(), "exp": time.Now().Add(-time.Hour).Unix()}),
+			action:        model.ScopeSubsRead,
+			status:        http.StatusUnauthorized,
+		},
+		{
+			name:          "basic scheme",
+			authorization: "Basic dXNlcjpwYXNz",
+			action:        model.ScopeSubsRead,
+			status:        http.StatusUnauthorized,
+		},
+		{
+			name:          "bearer without a token",
+			authorization: "Bearer ",
+			action:        model.ScopeSubsRead,
+			status:        http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(principalRouter(h, tt.action), map[string]string{"Authorization": tt.authorization})
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("WWW-Authenticate header is missing")
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var principal model.Principal
+			if err := json.Unmarshal(rec.Body.Bytes(), &principal); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(principal, tt.want) {
+				t.Errorf("principal = %+v, want %+v", principal, tt.want)
+			}
+		})
+	}
+}
+
+// staticAPIKeys accepts every key as key.
+type staticAPIKeys struct {
+	service.APIKey
+
+	key *model.APIKey
+}
+
+func (s *staticAPIKeys) AuthenticateAPIKey(context.Context, string) (*model.APIKey, error) {
+	return s.key, nil
+}
+
+func TestAuthMiddlewareSetsActorFromPrincipal(t *testing.T) {
+	verifier, err := jwt.NewVerifier(jwt.Config{HMACSecret: jwtSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &model.APIKey{ID: uuid.New(), Name: "billing"}
+	h := &Handler{
+		service:  &service.Service{APIKey: &staticAPIKeys{key: key}},
+		verifier: verifier,
+		devAuth:  true,
+		logger:   discardLogger,
+	}
+	router := gin.New()
+	router.GET("/", h.authMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, model.ActorFromContext(c.Request.Context()))
+	})
+	userID := uuid.New()
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"jwt subject", map[string]string{"Authorization": bearer(map[string]any{"sub": "ops"})}, "ops"},
+		{"api key", map[string]string{apiKeyHeader: "sk_billing"}, "api-key:billing"},
+		{"dev user", map[string]string{userIDHeader: userID.String()}, "dev:" + userID.String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.headers["X-Actor"] = "mallory"
+			rec := serve(router, tt.headers)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+			}
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("actor = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rezexell/em-test-task/internal/model"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 error body.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// errorStatus maps a domain error kind to an HTTP status code.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, model.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, model.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, model.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, model.ErrInvalidPeriod):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, model.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, model.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, model.ErrTooManyRequests):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorHandler renders the last error attached with c.Error as a problem
+// document. Errors without a domain kind are logged and reported as 500
+// without exposing their details.
+func errorHandler(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		status := errorStatus(err)
+
+		detail := err.Error()
+		if status == http.StatusInternalServerError {
+			logger.ErrorContext(c.Request.Context(), "request failed",
+				slog.String("path", c.FullPath()),
+				slog.Any("err", err.Error()))
+			detail = "internal server error"
+		}
+
+		c.Header("Content-Type", problemContentType)
+		c.JSON(status, Problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(status),
+			Status:   status,
+			Detail:   detail,
+			Instance: c.Request.URL.Path,
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/pkg/xlsx"
+)
+
+// Export formats.
+const (
+	exportCSV    = "csv"
+	exportNDJSON = "ndjson"
+	exportXLSX   = "xlsx"
+)
+
+var exportContentTypes = map[string]string{
+	exportCSV:    "text/csv; charset=utf-8",
+	exportNDJSON: "application/x-ndjson",
+	exportXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// exportColumns are the CSV and XLSX columns, in the format accepted by
+// ImportSubs.
+var exportColumns = []string{
+	"id", "service_name", "monthly_cost", "price", "billing_period",
+	"currency", "user_id", "start_date", "end_date",
+}
+
+// ExportSubs
+// @Summary Экспорт подписок
+// @Description Выгружает все подписки, подходящие под фильтры, в CSV, NDJSON или XLSX. Строки читаются из базы и отправляются потоком
+// @Tags subscriptions
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "Формат файла" Enums(csv, ndjson, xlsx) default(csv)
+// @Param user_id query string false "ID пользователя (UUID)"
+// @Param service_name query string false "Название сервиса"
+// @Param active_from query string false "Активна начиная с (MM/YYYY)" Example(03/2024)
+// @Param active_to query string false "Активна до (MM/YYYY)" Example(09/2024)
+// @Param starts_after query string false "Начинается после месяца (MM/YYYY)"
+// @Param ends_before query string false "Заканчивается до месяца (MM/YYYY)"
+// @Param open_ended query bool false "Только бессрочные (true) или только с датой окончания (false)"
+// @Param include_deleted query bool false "Включать удаленные подписки"
+// @Success 200 {file} file
+// @Failure 400 {object} handler.Problem "unsupported format \"pdf\""
+// @Failure 422 {object} handler.Problem "active_from cannot be after active_to"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /sub/export [get]
+func (h *Handler) ExportSubs(c *gin.Context) {
+	const fn = "handler.ExportSubs"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	format := c.DefaultQuery("format", exportCSV)
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		c.Error(model.Validationf("unsupported format %q", format))
+		return
+	}
+
+	filter, err := subscriptionFilter(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Exporters buffer their output, so nothing reaches the client before
+	// the first rows are read and a failing query can still be reported.
+	w := &deadlineWriter{w: c.Writer, rc: connController(c), timeout: h.writeTimeout}
+	exporter, err := newExporter(format, w)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="subscriptions.`+format+`"`)
+
+	err = h.service.ExportSubscriptions(c.Request.Context(), filter, exporter.Write)
+	if err == nil {
+		err = exporter.Close()
+	}
+	if err != nil {
+		if c.Writer.Written() {
+			h.logger.ErrorContext(c.Request.Context(), "export interrupted", slog.String("fn", fn), slog.Any("err", err))
+			c.Abort()
+			return
+		}
+		c.Writer.Header().Del("Content-Disposition")
+		c.Error(err)
+		return
+	}
+	return
+}
+
+const connControllerKey = "handler.connController"
+
+// connControllerMiddleware keeps a controller of the connection before
+// logging and metrics wrap the writer in ones that hide the connection.
+func connControllerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(connControllerKey, http.NewResponseController(c.Writer))
+		c.Next()
+	}
+}
+
+func connController(c *gin.Context) *http.ResponseController {
+	if rc, ok := c.Value(connControllerKey).(*http.ResponseController); ok {
+		return rc
+	}
+	return http.NewResponseController(c.Writer)
+}
+
+// deadlineWriter moves the write deadline of the connection before each
+// write, so that a long export is limited by the time one write takes rather
+// than by the server's write timeout for the whole response.
+type deadlineWriter struct {
+	w       io.Writer
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+func (w *deadlineWriter) Write(p []byte) (int, error) {
+	var deadline time.Time
+	if w.timeout > 0 {
+		deadline = time.Now().Add(w.timeout)
+	}
+	if err := w.rc.SetWriteDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
+
+type exporter interface {
+	Write(sub *model.Subscription) error
+	Close() error
+}
+
+func newExporter(format string, w io.Writer) (exporter, error) {
+	switch format {
+	case exportNDJSON:
+		buf := bufio.NewWriter(w)
+		return &ndjsonExporter{buf: buf, enc: json.NewEncoder(buf)}, nil
+	case exportXLSX:
+		xw, err := xlsx.NewWriter(w, "Subscriptions")
+		if err != nil {
+			return nil, err
+		}
+		header := make([]any, len(exportColumns))
+		for i, column := range exportColumns {
+			header[i] = column
+		}
+		return &xlsxExporter{w: xw}, xw.WriteRow(header...)
+	default:
+		cw := csv.NewWriter(w)
+		return &csvExporter{w: cw}, cw.Write(exportColumns)
+	}
+}
+
+// exportRecord returns the values of exportColumns. Dates are YYYY-MM-DD.
+func exportRecord(sub *model.Subscription) []any {
+	var endDate any
+	if sub.EndDate != nil {
+		endDate = sub.EndDate.Format(time.DateOnly)
+	}
+	return []any{
+		sub.ID.String(), sub.ServiceName, sub.MonthlyCost, sub.Price, sub.BillingPeriod,
+		sub.Currency, sub.UserID.String(), sub.StartDate.Format(time.DateOnly), endDate,
+	}
+}
+
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func (e *csvExporter) Write(sub *model.Subscription) error {
+	values := exportRecord(sub)
+	record := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case nil:
+		case int:
+			record[i] = strconv.Itoa(v)
+		default:
+			record[i] = v.(string)
+		}
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvExporter) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonExporter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *ndjsonExporter) Write(sub *model.Subscription) error {
+	return e.enc.Encode(sub.ToResponse())
+}
+
+func (e *ndjsonExporter) Close() error {
+	return e.buf.Flush()
+}
+
+type xlsxExporter struct {
+	w *xlsx.Writer
+}
+
+func (e *xlsxExporter) Write(sub *model.Subscription) error {
+	return e.w.WriteRow(exportRecord(sub)...)
+}
+
+func (e *xlsxExporter) Close() error {
+	return e.w.Close()
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/internal/service"
+)
+
+// slowExport streams rows for longer than the server's write timeout.
+type slowExport struct {
+	service.Subscription
+
+	rows  int
+	pause time.Duration
+}
+
+func (s *slowExport) ExportSubscriptions(ctx context.Context, _ model.SubscriptionFilter, fn func(*model.Subscription) error) error {
+	for i := 0; i < s.rows; i++ {
+		if i%100 == 0 {
+			time.Sleep(s.pause)
+		}
+		sub := &model.Subscription{
+			ID:            uuid.New(),
+			ServiceName:   strings.Repeat("Netflix ", 8),
+			MonthlyCost:   400,
+			Price:         400,
+			BillingPeriod: model.BillingMonthly,
+			Currency:      "RUB",
+			UserID:        uuid.New(),
+			StartDate:     time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		}
+		if err := fn(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExportOutlivesServerWriteTimeout(t *testing.T) {
+	const rows = 1000
+	services := &service.Service{Subscription: &slowExport{rows: rows, pause: 30 * time.Millisecond}}
+	h := NewHandler(services, Options{DevAuth: true, WriteTimeout: time.Second}, discardLogger)
+
+	server := httptest.NewUnstartedServer(h.InitRouter())
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/sub/export?format=ndjson", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(userIDHeader, uuid.NewString())
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var n int
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("after %d rows: %v", n, err)
+	}
+	if n != rows {
+		t.Errorf("got %d rows, want %d", n, rows)
+	}
+}
@@ -2,37 +2,139 @@ package handler
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/rezexell/em-test-task/internal/metrics"
+	"github.com/rezexell/em-test-task/internal/model"
 	"github.com/rezexell/em-test-task/internal/service"
+	"github.com/rezexell/em-test-task/pkg/jwt"
+	"github.com/rezexell/em-test-task/pkg/ratelimit"
 	sloggin "github.com/samber/slog-gin"
 	"github.com/swaggo/files"
 	"github.com/swaggo/gin-swagger"
 	"log/slog"
+	"time"
 )
 
 type Handler struct {
-	service *service.Service
-	logger  *slog.Logger
+	service   *service.Service
+	verifier  *jwt.Verifier
+	devAuth   bool
+	limiter   *ratelimit.Limiter
+	ipLimiter *ratelimit.Limiter
+	checks    map[string]ReadinessCheck
+	metrics   *metrics.Metrics
+	// writeTimeout bounds each write of a streamed response.
+	writeTimeout   time.Duration
+	trustedProxies []string
+	logger         *slog.Logger
 }
 
-func NewHandler(service *service.Service, logger *slog.Logger) *Handler {
-	return &Handler{service: service, logger: logger}
+// Options configure the handlers beyond the services they call.
+type Options struct {
+	// Verifier authenticates bearer JWTs. Without it only API keys are
+	// accepted.
+	Verifier *jwt.Verifier
+	// DevAuth lets callers without credentials act as the user named in
+	// the X-User-ID header. It is meant for local development only.
+	DevAuth bool
+	// Limiter rate limits authenticated callers and IPLimiter client IPs
+	// before authentication. A nil limiter disables that rate limiting.
+	Limiter   *ratelimit.Limiter
+	IPLimiter *ratelimit.Limiter
+	// Checks are run by /health/ready.
+	Checks map[string]ReadinessCheck
+	// Metrics records request metrics when it is not nil. They are served
+	// apart from the API.
+	Metrics *metrics.Metrics
+	// WriteTimeout bounds each write of a streamed export instead of the
+	// whole response, which the server's write timeout would cut off. Zero
+	// means no limit.
+	WriteTimeout time.Duration
+	// TrustedProxies are the IPs and CIDRs of proxies whose X-Forwarded-For
+	// header names the client. The connection's address is used for
+	// requests from anywhere else, and for all of them when it is empty.
+	TrustedProxies []string
+}
+
+// NewHandler creates the API handlers.
+func NewHandler(service *service.Service, opts Options, logger *slog.Logger) *Handler {
+	return &Handler{
+		service:        service,
+		verifier:       opts.Verifier,
+		devAuth:        opts.DevAuth,
+		limiter:        opts.Limiter,
+		ipLimiter:      opts.IPLimiter,
+		checks:         opts.Checks,
+		metrics:        opts.Metrics,
+		writeTimeout:   opts.WriteTimeout,
+		trustedProxies: opts.TrustedProxies,
+		logger:         logger,
+	}
 }
 
 func (h *Handler) InitRouter() *gin.Engine {
 	router := gin.New()
+	if err := router.SetTrustedProxies(h.trustedProxies); err != nil {
+		h.logger.Error("invalid trusted proxies, trusting none", slog.Any("err", err))
+		_ = router.SetTrustedProxies(nil)
+	}
 
 	router.Use(gin.Recovery())
+	router.Use(connControllerMiddleware())
+	router.Use(tracingMiddleware())
+	if h.metrics != nil {
+		router.Use(h.metricsMiddleware())
+	}
 	router.Use(sloggin.New(h.logger))
+	router.Use(errorHandler(h.logger))
+
+	sub := router.Group("/sub", h.ipRateLimit(), h.authMiddleware(), h.rateLimit())
+	{
+		read := sub.Group("", authorize(model.ScopeSubsRead))
+		read.GET("/:id/history", h.GetSubHistory)
+		read.GET("/:id/prices", h.GetSubPrices)
+		read.GET("/", h.GetAllSubs)
+		read.GET("/:id", h.GetSubByID)
+		read.GET("/filter/", h.GetFilteredSubs)
+		read.GET("/export", h.ExportSubs)
+
+		write := sub.Group("", authorize(model.ScopeSubsWrite))
+		write.POST("/", h.CreateSub)
+		write.POST("/import", h.ImportSubs)
+		write.PUT("/", h.UpdateSub)
+		write.PATCH("/:id", h.PatchSub)
+		write.DELETE("/:id", h.DeleteSub)
+		write.POST("/:id/restore", h.RestoreSub)
+		write.POST("/:id/prices", h.SchedulePrice)
+
+		reports := sub.Group("", authorize(model.ScopeReportsRead))
+		reports.GET("/total-cost/", h.GetTotalCost)
+		reports.GET("/cost-breakdown", h.GetCostBreakdown)
+	}
+
+	admin := router.Group("/admin", h.ipRateLimit(), h.authMiddleware(), h.rateLimit(), authorize(model.ScopeAdmin))
+	{
+		admin.PUT("/exchange-rates", h.SetExchangeRates)
+		admin.GET("/exchange-rates", h.GetExchangeRates)
+		admin.POST("/api-keys", h.CreateAPIKey)
+		admin.GET("/api-keys", h.GetAPIKeys)
+		admin.DELETE("/api-keys/:id", h.RevokeAPIKey)
+	}
+
+	webhooks := router.Group("/webhooks", h.ipRateLimit(), h.authMiddleware(), h.rateLimit(), authorize(model.ScopeAdmin))
+	{
+		webhooks.POST("", h.CreateWebhook)
+		webhooks.GET("", h.GetWebhooks)
+		webhooks.GET("/dead-letters", h.GetDeadLetters)
+		webhooks.POST("/dead-letters/:id/retry", h.RetryDeadLetter)
+		webhooks.GET("/:id", h.GetWebhook)
+		webhooks.PUT("/:id", h.UpdateWebhook)
+		webhooks.DELETE("/:id", h.DeleteWebhook)
+	}
 
-	sub := router.Group("/sub")
+	health := router.Group("/health")
 	{
-		sub.POST("/", h.CreateSub)
-		sub.PUT("/", h.UpdateSub)
-		sub.DELETE("/:id", h.DeleteSub)
-		sub.GET("/", h.GetAllSubs)
-		sub.GET("/:id", h.GetSubByID)
-		sub.GET("/filter/", h.GetFilteredSubs)
-		sub.GET("/total-cost/", h.GetTotalCost)
+		health.GET("/live", h.Live)
+		health.GET("/ready", h.Ready)
 	}
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReadinessCheck checks a dependency and returns details to report about
+// it.
+type ReadinessCheck func(ctx context.Context) (map[string]any, error)
+
+const readinessTimeout = 2 * time.Second
+
+// CheckResult is the outcome of one readiness check.
+type CheckResult struct {
+	Status    string         `json:"status" example:"ok"`
+	LatencyMs float64        `json:"latency_ms" example:"1.7"`
+	Error     string         `json:"error,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// Health is the body of the health endpoints.
+type Health struct {
+	Status string                 `json:"status" example:"ok"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Live
+// @Summary Проверка жизнеспособности
+// @Description Отвечает, пока процесс обрабатывает запросы. Зависимости не проверяются
+// @Tags health
+// @Produce json
+// @Success 200 {object} handler.Health
+// @Router /health/live [get]
+func (h *Handler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, Health{Status: "ok"})
+	return
+}
+
+// Ready
+// @Summary Проверка готовности
+// @Description Проверяет зависимости (база данных, версия миграций) и возвращает результат и задержку каждой проверки. Если хотя бы одна проверка не прошла, возвращается 503
+// @Tags health
+// @Produce json
+// @Success 200 {object} handler.Health
+// @Failure 503 {object} handler.Health
+// @Router /health/ready [get]
+func (h *Handler) Ready(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	health := Health{Status: "ok", Checks: make(map[string]CheckResult, len(h.checks))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, check := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			details, err := check(ctx)
+			result := CheckResult{
+				Status:    "ok",
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+				Details:   details,
+			}
+			if err != nil {
+				result.Status = "fail"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			health.Checks[name] = result
+			if err != nil {
+				health.Status = "fail"
+			}
+		}()
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if health.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, health)
+	return
+}
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+)
+
+// ImportSubs
+// @Summary Импорт подписок
+// @Description Создает подписки из CSV (с заголовком из полей подписки) или NDJSON. В режиме atomic подписки создаются только если корректны все строки, в режиме partial создаются корректные строки
+// @Tags subscriptions
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Param mode query string false "Режим импорта" Enums(atomic, partial) default(atomic)
+// @Param input body string true "CSV: service_name,price,billing_period,currency,user_id,start_date,end_date"
+// @Success 200 {object} model.ImportReport
+// @Failure 400 {object} handler.Problem "unsupported content type application/json, use text/csv or application/x-ndjson"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /sub/import [post]
+func (h *Handler) ImportSubs(c *gin.Context) {
+	const fn = "handler.ImportSubs"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	var (
+		rows []*model.ImportRow
+		err  error
+	)
+	switch c.ContentType() {
+	case "text/csv":
+		rows, err = parseImportCSV(c.Request.Body)
+	case "application/x-ndjson", "application/jsonl":
+		rows, err = parseImportNDJSON(c.Request.Body)
+	default:
+		err = model.Validationf("unsupported content type %s, use text/csv or application/x-ndjson", c.ContentType())
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	report, err := h.service.ImportSubscriptions(c.Request.Context(), rows, c.Query("mode"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+	return
+}
+
+// importColumns are the CSV columns an import may contain.
+var importColumns = map[string]bool{
+	"id": true, "service_name": true, "monthly_cost": true, "price": true, "billing_period": true,
+	"currency": true, "user_id": true, "start_date": true, "end_date": true,
+}
+
+// parseImportCSV reads subscriptions from CSV whose first record names the
+// columns. Malformed CSV fails the whole import, invalid values fail a row.
+func parseImportCSV(r io.Reader) ([]*model.ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, model.Validationf("import contains no rows")
+	}
+	if err != nil {
+		return nil, model.Validationf("%v", err)
+	}
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		if !importColumns[header[i]] {
+			return nil, model.Validationf("unknown column %q", column)
+		}
+	}
+
+	var rows []*model.ImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, model.Validationf("%v", err)
+		}
+		if len(rows) == model.MaxImportRows {
+			return nil, model.Validationf("import cannot contain more than %d rows", model.MaxImportRows)
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := &model.ImportRow{Line: line}
+		row.Subscription, row.Err = csvSubscription(header, record)
+		if row.Err == nil {
+			row.Err = prepareImported(row.Subscription)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func csvSubscription(header, record []string) (*model.Subscription, error) {
+	var sub model.Subscription
+	for i, column := range header {
+		value := strings.TrimSpace(record[i])
+		if value == "" {
+			continue
+		}
+
+		var err error
+		switch column {
+		case "id":
+			sub.ID, err = uuid.Parse(value)
+		case "service_name":
+			sub.ServiceName = value
+		case "monthly_cost":
+			sub.MonthlyCost, err = strconv.Atoi(value)
+		case "price":
+			sub.Price, err = strconv.Atoi(value)
+		case "billing_period":
+			sub.BillingPeriod = value
+		case "currency":
+			sub.Currency = value
+		case "user_id":
+			sub.UserID, err = uuid.Parse(value)
+		case "start_date":
+			sub.StartDateStr = value
+		case "end_date":
+			sub.EndDateStr = value
+		}
+		if err != nil {
+			return nil, model.Validationf("invalid %s %q", column, value)
+		}
+	}
+	return &sub, nil
+}
+
+// parseImportNDJSON reads one JSON subscription per line. Blank lines are
+// skipped.
+func parseImportNDJSON(r io.Reader) ([]*model.ImportRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var rows []*model.ImportRow
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if len(rows) == model.MaxImportRows {
+			return nil, model.Validationf("import cannot contain more than %d rows", model.MaxImportRows)
+		}
+
+		row := &model.ImportRow{Line: line}
+		var sub model.Subscription
+		if err := json.Unmarshal(data, &sub); err != nil {
+			row.Err = model.Validationf("%v", err)
+		} else {
+			row.Subscription = &sub
+			row.Err = prepareImported(&sub)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, model.Validationf("%v", err)
+	}
+
+	return rows, nil
+}
+
+// prepareImported validates an imported subscription the way CreateSub does
+// for a request body and assigns its ID.
+func prepareImported(sub *model.Subscription) error {
+	if err := binding.Validator.ValidateStruct(sub); err != nil {
+		return model.Validationf("%v", err)
+	}
+	if err := sub.AfterBind(); err != nil {
+		return model.Validationf("%v", err)
+	}
+
+	if sub.ID == uuid.Nil {
+		sub.ID = uuid.New()
+	}
+	return nil
+}
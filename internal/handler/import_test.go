@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rezexell/em-test-task/internal/model"
+)
+
+const importUserID = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+func TestParseImportLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string) ([]*model.ImportRow, error)
+		input string
+		lines []int
+		valid []bool
+	}{
+		{
+			name:  "csv",
+			parse: func(s string) ([]*model.ImportRow, error) { return parseImportCSV(strings.NewReader(s)) },
+			input: "service_name,price,user_id,start_date\n" +
+				"Netflix,400," + importUserID + ",07/2024\n" +
+				"Netflix,-1," + importUserID + ",07/2024\n" +
+				"\"Yandex\nPlus\",400," + importUserID + ",07/2024\n" +
+				"Kinopoisk,400," + importUserID + ",13/2024\n",
+			lines: []int{2, 3, 4, 6},
+			valid: []bool{true, false, true, false},
+		},
+		{
+			name:  "ndjson",
+			parse: func(s string) ([]*model.ImportRow, error) { return parseImportNDJSON(strings.NewReader(s)) },
+			input: `{"service_name": "Netflix", "price": 400, "user_id": "` + importUserID + `", "start_date": "07/2024"}` + "\n" +
+				"\n" +
+				`{"service_name": "Netflix", "price": 400` + "\n" +
+				"   \n" +
+				`{"service_name": "Netflix", "price": 400, "user_id": "` + importUserID + `", "start_date": "07/2024"}` + "\n",
+			lines: []int{1, 3, 5},
+			valid: []bool{true, false, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := tt.parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != len(tt.lines) {
+				t.Fatalf("got %d rows, want %d", len(rows), len(tt.lines))
+			}
+			for i, row := range rows {
+				if row.Line != tt.lines[i] {
+					t.Errorf("row %d: line %d, want %d", i, row.Line, tt.lines[i])
+				}
+				if (row.Err == nil) != tt.valid[i] {
+					t.Errorf("line %d: err = %v, want valid %v", row.Line, row.Err, tt.valid[i])
+				}
+			}
+		})
+	}
+}
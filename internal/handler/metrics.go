@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metricsMiddleware records the latency and status of every request by
+// route pattern. Requests matching no route share the "unmatched" route.
+func (h *Handler) metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		h.metrics.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rezexell/em-test-task/internal/metrics"
+	"github.com/rezexell/em-test-task/internal/repository"
+	"github.com/rezexell/em-test-task/internal/service"
+	"github.com/rezexell/em-test-task/internal/testdb"
+)
+
+func TestMetricsAreNotServedOnTheAPI(t *testing.T) {
+	m := metrics.New()
+	services := service.NewService(repository.NewRepository(testdb.DryRun(t).DB))
+	router := NewHandler(services, Options{DevAuth: true, Metrics: m}, discardLogger).InitRouter()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("GET /metrics on the API: status = %d, want 404", rec.Code)
+	}
+
+	// The API still records its requests for the metrics listener.
+	rec = httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), `http_requests_total{method="GET",route="unmatched",status="404"}`) {
+		t.Errorf("request to the API was not recorded:\n%s", rec.Body)
+	}
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+)
+
+const monthLayout = "01/2006"
+
+// parseMonth parses a MM/YYYY value into the first day of that month.
+func parseMonth(value string) (time.Time, error) {
+	month, err := time.Parse(monthLayout, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+// endOfMonth returns the last day of the month t belongs to.
+func endOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+}
+
+// monthQuery reads an optional MM/YYYY query parameter. When last is true the
+// returned date is the last day of the month, otherwise the first one.
+func monthQuery(c *gin.Context, name string, last bool) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	month, err := parseMonth(value)
+	if err != nil {
+		return nil, model.Validationf("invalid %s format, use MM/YYYY", name)
+	}
+	if last {
+		month = endOfMonth(month)
+	}
+	return &month, nil
+}
+
+func uuidQuery(c *gin.Context, name string) (*uuid.UUID, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return nil, model.Validationf("invalid %s format", name)
+	}
+	return &id, nil
+}
+
+func stringQuery(c *gin.Context, name string) *string {
+	value := c.Query(name)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func boolQuery(c *gin.Context, name string) (*bool, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, model.Validationf("invalid %s value, use true or false", name)
+	}
+	return &b, nil
+}
+
+// timeQuery reads an optional YYYY-MM-DD or RFC 3339 query parameter.
+// A bare date stands for the end of that day.
+func timeQuery(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	day, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, model.Validationf("invalid %s format, use YYYY-MM-DD or RFC 3339", name)
+	}
+	endOfDay := day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	return &endOfDay, nil
+}
+
+// includeDeletedQuery reads the include_deleted opt-in flag.
+func includeDeletedQuery(c *gin.Context) (bool, error) {
+	includeDeleted, err := boolQuery(c, "include_deleted")
+	if err != nil || includeDeleted == nil {
+		return false, err
+	}
+	return *includeDeleted, nil
+}
+
+// limitQuery reads the limit query parameter, DefaultPageLimit when absent.
+func limitQuery(c *gin.Context) (int, error) {
+	value := c.Query("limit")
+	if value == "" {
+		return model.DefaultPageLimit, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, model.Validationf("invalid limit format")
+	}
+	return limit, nil
+}
+
+// pageRequest reads limit, cursor, sort and order query parameters.
+func pageRequest(c *gin.Context) (model.PageRequest, error) {
+	page := model.PageRequest{
+		Limit:  model.DefaultPageLimit,
+		Cursor: c.Query("cursor"),
+		SortBy: c.DefaultQuery("sort", model.SortByStartDate),
+	}
+
+	limit, err := limitQuery(c)
+	if err != nil {
+		return page, err
+	}
+	page.Limit = limit
+
+	switch c.DefaultQuery("order", "desc") {
+	case "asc":
+	case "desc":
+		page.Desc = true
+	default:
+		return page, model.Validationf("invalid order value, use asc or desc")
+	}
+
+	return page, page.Validate()
+}
+
+func pageResponse(page *model.SubscriptionPage) gin.H {
+	items := make([]gin.H, 0, len(page.Items))
+	for _, sub := range page.Items {
+		items = append(items, sub.ToResponse())
+	}
+
+	var nextCursor *string
+	if page.NextCursor != "" {
+		nextCursor = &page.NextCursor
+	}
+
+	return gin.H{"items": items, "next_cursor": nextCursor}
+}
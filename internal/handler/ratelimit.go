@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/pkg/ratelimit"
+)
+
+// rateLimit takes a token from the bucket of the caller for the route and
+// rejects the request with 429 when it is empty. It runs after
+// authentication, so that callers are told apart by their credentials.
+func (h *Handler) rateLimit() gin.HandlerFunc {
+	return h.limitRequests(h.limiter, clientKey)
+}
+
+// ipRateLimit takes a token from the bucket of the client IP before the
+// request is authenticated, which limits credential checks, and their
+// database lookups, of clients sending invalid or many different keys.
+func (h *Handler) ipRateLimit() gin.HandlerFunc {
+	return h.limitRequests(h.ipLimiter, func(c *gin.Context) string {
+		return "ip:" + c.ClientIP()
+	})
+}
+
+// limitRequests rejects requests with 429 when the bucket of their client
+// key for the route is empty. The limits are reported in the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers. When
+// the limiter store fails the request is let through. A nil limiter
+// disables limiting.
+func (h *Handler) limitRequests(limiter *ratelimit.Limiter, key func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil {
+			c.Next()
+			return
+		}
+
+		res, err := limiter.Allow(c.Request.Context(), c.Request.Method+" "+c.FullPath(), key(c))
+		if err != nil {
+			h.logger.WarnContext(c.Request.Context(), "rate limiter unavailable", slog.Any("err", err))
+			c.Next()
+			return
+		}
+		if res.Limit == 0 {
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		if !res.Allowed {
+			retryAfter := ceilSeconds(res.RetryAfter)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.Error(model.TooManyRequestsf("rate limit exceeded, retry in %d s", retryAfter))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// clientKey identifies the caller by API key ID, user or subject, falling
+// back to the client IP for anonymous requests. Key names are not unique, so
+// keys sharing one do not share a bucket.
+func clientKey(c *gin.Context) string {
+	principal, ok := model.PrincipalFromContext(c.Request.Context())
+	switch {
+	case !ok:
+		return "ip:" + c.ClientIP()
+	case principal.APIKeyID != nil:
+		return "key:" + principal.APIKeyID.String()
+	case principal.UserID != nil:
+		return "user:" + principal.UserID.String()
+	case principal.Subject != "":
+		return "sub:" + principal.Subject
+	default:
+		return "ip:" + c.ClientIP()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/internal/service"
+	"github.com/rezexell/em-test-task/pkg/ratelimit"
+)
+
+func TestClientKey(t *testing.T) {
+	userID := uuid.New()
+	first := &model.APIKey{ID: uuid.New(), Name: "billing", Scopes: []string{model.ScopeSubsRead}}
+	second := &model.APIKey{ID: uuid.New(), Name: "billing", Scopes: []string{model.ScopeSubsRead}, UserID: &userID}
+
+	tests := []struct {
+		name      string
+		principal *model.Principal
+		want      string
+	}{
+		{"anonymous", nil, "ip:192.0.2.1"},
+		{"api key", first.Principal(), "key:" + first.ID.String()},
+		{"api key of a user with a taken name", second.Principal(), "key:" + second.ID.String()},
+		{"user", &model.Principal{Subject: userID.String(), UserID: &userID}, "user:" + userID.String()},
+		{"service", &model.Principal{Subject: "billing-service", Roles: []string{model.RoleAnalyst}}, "sub:billing-service"},
+		{"no subject", &model.Principal{}, "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request.RemoteAddr = "192.0.2.1:1234"
+			if tt.principal != nil {
+				c.Request = c.Request.WithContext(model.WithPrincipal(c.Request.Context(), tt.principal))
+			}
+
+			if got := clientKey(c); got != tt.want {
+				t.Errorf("clientKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPTrustsOnlyConfiguredProxies(t *testing.T) {
+	tests := []struct {
+		name       string
+		proxies    []string
+		remoteAddr string
+		want       string
+	}{
+		{"no trusted proxies", nil, "192.0.2.1:1234", "192.0.2.1"},
+		{"request from a trusted proxy", []string{"192.0.2.0/24"}, "192.0.2.1:1234", "203.0.113.7"},
+		{"request from elsewhere", []string{"192.0.2.0/24"}, "198.51.100.1:1234", "198.51.100.1"},
+		{"invalid proxies trust none", []string{"proxy.internal"}, "192.0.2.1:1234", "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewHandler(&service.Service{}, Options{TrustedProxies: tt.proxies}, discardLogger).InitRouter()
+			router.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+
+			req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", "203.0.113.7")
+			req.Header.Set("X-Real-IP", "203.0.113.7")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("client IP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// countingAPIKeys rejects every key and counts the checks.
+type countingAPIKeys struct {
+	service.APIKey
+
+	checks int
+}
+
+func (s *countingAPIKeys) AuthenticateAPIKey(context.Context, string) (*model.APIKey, error) {
+	s.checks++
+	return nil, model.Unauthorizedf("invalid api key")
+}
+
+func TestIPRateLimitRunsBeforeAuthentication(t *testing.T) {
+	keys := &countingAPIKeys{}
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.Limit{Rate: 1.0 / 60, Burst: 2}, nil)
+	router := NewHandler(&service.Service{APIKey: keys}, Options{IPLimiter: limiter}, discardLogger).InitRouter()
+
+	send := func(remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/sub/", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(apiKeyHeader, "sk_guess")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusTooManyRequests} {
+		if got := send("192.0.2.1:1234"); got != want {
+			t.Fatalf("request %d: status = %d, want %d", i+1, got, want)
+		}
+	}
+	if keys.checks != 2 {
+		t.Errorf("checked %d keys, want 2", keys.checks)
+	}
+	if got := send("192.0.2.2:1234"); got != http.StatusUnauthorized {
+		t.Errorf("another IP: status = %d, want 401", got)
+	}
+}
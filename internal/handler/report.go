@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rezexell/em-test-task/internal/model"
+)
+
+// GetCostBreakdown
+// @Summary Детализация стоимости
+// @Description Рассчитывает стоимость подписок за период с группировкой по месяцам, сервисам и пользователям
+// @Tags reports
+// @Produce json
+// @Param user_id query string false "ID пользователя (UUID)"
+// @Param service_name query string false "Название сервиса"
+// @Param start_period query string true "Начало периода (MM/YYYY)" Example(01/2023)
+// @Param end_period query string true "Конец периода (MM/YYYY)" Example(12/2023)
+// @Param include_deleted query bool false "Учитывать удаленные подписки"
+// @Param group_by query string false "Группировка через запятую: month, service, user" default(month)
+// @Success 200 {object} map[string]interface{} "Пример: {\"group_by\": [\"month\"], \"items\": [{\"month\": \"01/2023\", \"currency\": \"RUB\", \"total_cost\": 400}], \"totals\": {\"RUB\": 400}}"
+// @Failure 400 {object} handler.Problem "unsupported group_by value \"day\""
+// @Failure 422 {object} handler.Problem "start period cannot be after end period"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /sub/cost-breakdown [get]
+func (h *Handler) GetCostBreakdown(c *gin.Context) {
+	const fn = "handler.GetCostBreakdown"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	query, err := costQuery(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	groupBy := strings.Split(c.DefaultQuery("group_by", model.GroupByMonth), ",")
+	for i := range groupBy {
+		groupBy[i] = strings.TrimSpace(groupBy[i])
+	}
+
+	rows, err := h.service.CostBreakdown(c.Request.Context(), query, groupBy)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	totals := make(map[string]int)
+	for _, row := range rows {
+		totals[row.Currency] += row.TotalCost
+	}
+	if rows == nil {
+		rows = []*model.CostBreakdownRow{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"group_by": groupBy,
+		"items":    rows,
+		"totals":   totals,
+	})
+	return
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/rezexell/em-test-task/internal/model"
 	"log/slog"
 	"net/http"
-	"time"
 )
 
 // CreateSub
@@ -17,20 +16,23 @@ import (
 // @Produce json
 // @Param input body model.Subscription true "Данные подписки"
 // @Success 201 {object} map[string]string
-// @Failure 400 {object} map[string]string "Пример: {\"error\": \"invalid UUID format\"}"
-// @Failure 500 {object} map[string]string "Пример: {\"error\": \"database connection failed\"}"
+// @Failure 400 {object} handler.Problem "invalid UUID format"
+// @Failure 409 {object} handler.Problem "subscription already exists"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /sub [post]
 func (h *Handler) CreateSub(c *gin.Context) {
 	const fn = "handler.CreateSub"
-	h.logger.Info("context", slog.String("fn", fn))
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
 
 	var sub model.Subscription
 	if err := c.ShouldBindJSON(&sub); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(model.Validationf("%v", err))
 		return
 	}
 	if err := sub.AfterBind(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(model.Validationf("%v", err))
 		return
 	}
 
@@ -39,7 +41,7 @@ func (h *Handler) CreateSub(c *gin.Context) {
 	}
 
 	if err := h.service.CreateSubscription(c.Request.Context(), &sub); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -49,37 +51,40 @@ func (h *Handler) CreateSub(c *gin.Context) {
 
 // UpdateSub
 // @Summary Обновить существующую подписку
-// @Description Обновляет данные подписки по ID
+// @Description Обновляет данные подписки по ID. Новая цена действует для списаний с сегодняшнего дня, прошедшие списания учитываются по прежней цене
 // @Tags subscriptions
 // @Accept json
 // @Produce json
 // @Param input body model.Subscription true "Обновленные данные подписки"
 // @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string "Пример: {\"error\": \"start_date: required field\"}"
-// @Failure 500 {object} map[string]string "Пример: {\"error\": \"subscription not found\"}"
+// @Failure 400 {object} handler.Problem "start_date: required field"
+// @Failure 404 {object} handler.Problem "subscription not found"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /sub [put]
 func (h *Handler) UpdateSub(c *gin.Context) {
 	const fn = "handler.UpdateSub"
-	h.logger.Info("context", slog.String("fn", fn))
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
 
 	var sub model.Subscription
 	if err := c.ShouldBindJSON(&sub); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		c.Error(model.Validationf("%v", err))
 		return
 	}
 
 	if err := sub.AfterBind(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(model.Validationf("%v", err))
 		return
 	}
 
 	if sub.ID == uuid.Nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "id is required"})
+		c.Error(model.Validationf("id is required"))
 		return
 	}
 
 	if err := h.service.UpdateSubscription(c.Request.Context(), &sub); err != nil {
-		c.JSON(http.StatusInternalServerError, err.Error())
+		c.Error(err)
 		return
 	}
 
@@ -87,6 +92,54 @@ func (h *Handler) UpdateSub(c *gin.Context) {
 	return
 }
 
+// PatchSub
+// @Summary Частично обновить подписку
+// @Description Применяет JSON Merge Patch (RFC 7396). "end_date": null делает подписку бессрочной. Новая цена действует для списаний с сегодняшнего дня, прошедшие списания учитываются по прежней цене. price - сумма одного списания за итоговый период: переданный billing_period, иначе сохраненный. monthly_cost заменяет price только при помесячном списании и не принимается вместе с price. Смена billing_period требует цены нового периода в том же запросе
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "ID подписки (UUID)"
+// @Param input body map[string]interface{} true "Изменяемые поля подписки"
+// @Success 200 {object} model.Subscription
+// @Failure 400 {object} handler.Problem "invalid end_date format, use YYYY-MM-DD or MM/YYYY"
+// @Failure 404 {object} handler.Problem "subscription not found"
+// @Failure 422 {object} handler.Problem "end_date cannot be before start_date"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /sub/{id} [patch]
+func (h *Handler) PatchSub(c *gin.Context) {
+	const fn = "handler.PatchSub"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(model.Validationf("invalid id"))
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		c.Error(model.Validationf("invalid request"))
+		return
+	}
+
+	patch, err := model.ParseSubscriptionPatch(body)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	sub, err := h.service.PatchSubscription(c.Request.Context(), id, patch)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sub.ToResponse())
+	return
+}
+
 // DeleteSub
 // @Summary Удалить подписку
 // @Description Удаляет подписку по ID
@@ -94,52 +147,245 @@ func (h *Handler) UpdateSub(c *gin.Context) {
 // @Produce json
 // @Param id path string true "ID подписки (UUID)"
 // @Success 204
-// @Failure 400 {object} map[string]string "Пример: {\"error\": \"invalid id format\"}"
-// @Failure 500 {object} map[string]string "Пример: {\"error\": \"delete operation failed\"}"
+// @Failure 400 {object} handler.Problem "invalid id"
+// @Failure 404 {object} handler.Problem "subscription not found"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /sub/{id} [delete]
 func (h *Handler) DeleteSub(c *gin.Context) {
 	const fn = "handler.DeleteSub"
-	h.logger.Info("context", slog.String("fn", fn))
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
 
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		c.Error(model.Validationf("invalid id"))
 		return
 	}
 	if err := h.service.DeleteSubscription(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusNoContent, gin.H{"message": "subscription deleted"})
 	return
 }
 
+// RestoreSub
+// @Summary Восстановить подписку
+// @Description Восстанавливает удаленную подписку по ID
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "ID подписки (UUID)"
+// @Success 200 {object} model.Subscription
+// @Failure 400 {object} handler.Problem "invalid id"
+// @Failure 404 {object} handler.Problem "subscription not found"
+// @Failure 409 {object} handler.Problem "subscription is not deleted"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /sub/{id}/restore [post]
+func (h *Handler) RestoreSub(c *gin.Context) {
+	const fn = "handler.RestoreSub"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(model.Validationf("invalid id"))
+		return
+	}
+
+	sub, err := h.service.RestoreSubscription(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sub.ToResponse())
+	return
+}
+
+// GetSubHistory
+// @Summary История изменений подписки
+// @Description Возвращает версии подписки. С параметром as_of возвращает состояние подписки на указанную дату
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "ID подписки (UUID)"
+// @Param as_of query string false "Дата (YYYY-MM-DD или RFC 3339)" Example(2024-06-30)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} handler.Problem "invalid as_of format, use YYYY-MM-DD or RFC 3339"
+// @Failure 404 {object} handler.Problem "subscription not found"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /sub/{id}/history [get]
+func (h *Handler) GetSubHistory(c *gin.Context) {
+	const fn = "handler.GetSubHistory"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(model.Validationf("invalid id"))
+		return
+	}
+
+	asOf, err := timeQuery(c, "as_of")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if asOf != nil {
+		event, version, err := h.service.SubscriptionStateAt(c.Request.Context(), id, *asOf)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"as_of":   *asOf,
+			"version": version,
+			"state":   event.State(),
+		})
+		return
+	}
+
+	events, err := h.service.SubscriptionHistory(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	versions := make([]gin.H, 0, len(events))
+	for i, event := range events {
+		versions = append(versions, event.ToResponse(i+1))
+	}
+	c.JSON(http.StatusOK, versions)
+	return
+}
+
+// SchedulePrice
+// @Summary Запланировать изменение цены
+// @Description Задает новую цену списания подписки начиная с будущего месяца
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "ID подписки (UUID)"
+// @Param input body model.SubscriptionPrice true "Новая цена и месяц начала ее действия"
+// @Success 201 {object} model.SubscriptionPrice
+// @Failure 400 {object} handler.Problem "effective_from: required field"
+// @Failure 404 {object} handler.Problem "subscription not found"
+// @Failure 409 {object} handler.Problem "price change effective from 07/2026 already scheduled"
+// @Failure 422 {object} handler.Problem "effective_from must be a future month"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /sub/{id}/prices [post]
+func (h *Handler) SchedulePrice(c *gin.Context) {
+	const fn = "handler.SchedulePrice"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(model.Validationf("invalid id"))
+		return
+	}
+
+	var price model.SubscriptionPrice
+	if err := c.ShouldBindJSON(&price); err != nil {
+		c.Error(model.Validationf("%v", err))
+		return
+	}
+	if err := price.AfterBind(); err != nil {
+		c.Error(model.Validationf("%v", err))
+		return
+	}
+	price.SubscriptionID = id
+
+	if err := h.service.SchedulePriceChange(c.Request.Context(), &price); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, price.ToResponse())
+	return
+}
+
+// GetSubPrices
+// @Summary Изменения цены подписки
+// @Description Возвращает запланированные и прошедшие изменения цены списания
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "ID подписки (UUID)"
+// @Success 200 {array} model.SubscriptionPrice
+// @Failure 400 {object} handler.Problem "invalid id"
+// @Failure 404 {object} handler.Problem "subscription not found"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /sub/{id}/prices [get]
+func (h *Handler) GetSubPrices(c *gin.Context) {
+	const fn = "handler.GetSubPrices"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(model.Validationf("invalid id"))
+		return
+	}
+
+	prices, err := h.service.ListPriceChanges(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := make([]gin.H, 0, len(prices))
+	for _, price := range prices {
+		response = append(response, price.ToResponse())
+	}
+	c.JSON(http.StatusOK, response)
+	return
+}
+
 // GetAllSubs
 // @Summary Получить все подписки
-// @Description Возвращает список всех подписок
+// @Description Возвращает страницу подписок (keyset-пагинация)
 // @Tags subscriptions
 // @Produce json
-// @Success 200 {array} model.Subscription
-// @Failure 500 {object} map[string]string "Пример: {\"error\": \"failed to fetch subscriptions\"}"
+// @Param limit query int false "Размер страницы (1-500)" default(50)
+// @Param cursor query string false "Курсор следующей страницы (next_cursor)"
+// @Param sort query string false "Поле сортировки" Enums(start_date, monthly_cost, service_name, created_at) default(start_date)
+// @Param order query string false "Направление сортировки" Enums(asc, desc) default(desc)
+// @Param include_deleted query bool false "Включать удаленные подписки"
+// @Success 200 {object} map[string]interface{} "Пример: {\"items\": [], \"next_cursor\": null}"
+// @Failure 400 {object} handler.Problem "invalid cursor"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /sub [get]
 func (h *Handler) GetAllSubs(c *gin.Context) {
 	const fn = "handler.GetAllSubs"
-	h.logger.Info("context", slog.String("fn", fn))
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
 
-	subs, err := h.service.ListAllSubscriptions(c.Request.Context())
+	page, err := pageRequest(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	if len(subs) == 0 || subs == nil {
-		subs = []*model.Subscription{}
+
+	includeDeleted, err := includeDeletedQuery(c)
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
-	response := make([]gin.H, 0, len(subs))
-	for _, sub := range subs {
-		response = append(response, sub.ToResponse())
+	subs, err := h.service.ListAllSubscriptions(c.Request.Context(), includeDeleted, page)
+	if err != nil {
+		c.Error(err)
+		return
 	}
-	c.JSON(http.StatusOK, response)
+
+	c.JSON(http.StatusOK, pageResponse(subs))
 	return
 }
 
@@ -150,27 +396,25 @@ func (h *Handler) GetAllSubs(c *gin.Context) {
 // @Produce json
 // @Param id path string true "ID подписки (UUID)"
 // @Success 200 {object} model.Subscription
-// @Failure 400 {object} map[string]string "Пример: {\"error\": \"invalid UUID format\"}"
-// @Failure 404 {object} map[string]string "Пример: {\"error\": \"subscription not found\"}"
-// @Failure 500 {object} map[string]string "Пример: {\"error\": \"database query failed\"}"
+// @Failure 400 {object} handler.Problem "invalid id"
+// @Failure 404 {object} handler.Problem "subscription not found"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /sub/{id} [get]
 func (h *Handler) GetSubByID(c *gin.Context) {
 	const fn = "handler.GetSubByID"
-	h.logger.Info("context", slog.String("fn", fn))
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
 
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		c.Error(model.Validationf("invalid id"))
 		return
 	}
 
 	sub, err := h.service.GetSubscription(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	if sub == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		c.Error(err)
 		return
 	}
 
@@ -185,121 +429,160 @@ func (h *Handler) GetSubByID(c *gin.Context) {
 // @Produce json
 // @Param user_id query string false "ID пользователя (UUID)"
 // @Param service_name query string false "Название сервиса"
-// @Success 200 {array} model.Subscription
-// @Failure 400 {object} map[string]string "Пример: {\"error\": \"invalid user_id format\"}"
-// @Failure 500 {object} map[string]string "Пример: {\"error\": \"filtering failed\"}"
+// @Param active_from query string false "Активна начиная с (MM/YYYY)" Example(03/2024)
+// @Param active_to query string false "Активна до (MM/YYYY)" Example(09/2024)
+// @Param starts_after query string false "Начинается после месяца (MM/YYYY)"
+// @Param ends_before query string false "Заканчивается до месяца (MM/YYYY)"
+// @Param open_ended query bool false "Только бессрочные (true) или только с датой окончания (false)"
+// @Param limit query int false "Размер страницы (1-500)" default(50)
+// @Param cursor query string false "Курсор следующей страницы (next_cursor)"
+// @Param sort query string false "Поле сортировки" Enums(start_date, monthly_cost, service_name, created_at) default(start_date)
+// @Param order query string false "Направление сортировки" Enums(asc, desc) default(desc)
+// @Param include_deleted query bool false "Включать удаленные подписки"
+// @Success 200 {object} map[string]interface{} "Пример: {\"items\": [], \"next_cursor\": null}"
+// @Failure 400 {object} handler.Problem "invalid user_id format"
+// @Failure 422 {object} handler.Problem "active_from cannot be after active_to"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /sub/filter [get]
 func (h *Handler) GetFilteredSubs(c *gin.Context) {
 	const fn = "handler.GetFilteredSubs"
-	h.logger.Info("context", slog.String("fn", fn))
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
 
-	userIDStr := c.Query("user_id")
-	serviceName := c.Query("service_name")
+	filter, err := subscriptionFilter(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
-	var userIDPtr *uuid.UUID
-	if userIDStr != "" {
-		userID, err := uuid.Parse(userIDStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id format"})
-			return
-		}
-		userIDPtr = &userID
+	page, err := pageRequest(c)
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
-	var serviceNamePtr *string
-	if serviceName != "" {
-		serviceNamePtr = &serviceName
+	subs, err := h.service.ListSubscriptionsWithFilters(c.Request.Context(), filter, page)
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
-	subs, err := h.service.ListSubscriptionsWithFilters(
-		c.Request.Context(),
-		userIDPtr,
-		serviceNamePtr,
+	c.JSON(http.StatusOK, pageResponse(subs))
+	return
+}
+
+func subscriptionFilter(c *gin.Context) (model.SubscriptionFilter, error) {
+	var (
+		filter model.SubscriptionFilter
+		err    error
 	)
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if filter.UserID, err = uuidQuery(c, "user_id"); err != nil {
+		return filter, err
 	}
+	filter.ServiceName = stringQuery(c, "service_name")
 
-	if subs == nil {
-		subs = []*model.Subscription{}
+	if filter.ActiveFrom, err = monthQuery(c, "active_from", false); err != nil {
+		return filter, err
+	}
+	if filter.ActiveTo, err = monthQuery(c, "active_to", true); err != nil {
+		return filter, err
+	}
+	if filter.StartsAfter, err = monthQuery(c, "starts_after", true); err != nil {
+		return filter, err
+	}
+	if filter.EndsBefore, err = monthQuery(c, "ends_before", false); err != nil {
+		return filter, err
+	}
+	if filter.OpenEnded, err = boolQuery(c, "open_ended"); err != nil {
+		return filter, err
+	}
+	if filter.IncludeDeleted, err = includeDeletedQuery(c); err != nil {
+		return filter, err
 	}
 
-	c.JSON(http.StatusOK, subs)
-	return
+	return filter, nil
 }
 
-// GetTotalCost
-// @Summary Расчет общей стоимости
-// @Description Рассчитывает общую стоимость подписок за период
-// @Tags subscriptions
-// @Produce json
-// @Param user_id query string false "ID пользователя (UUID)"
-// @Param service_name query string false "Название сервиса"
-// @Param start_period query string true "Начало периода (MM/YYYY)" Example(01/2023)
-// @Param end_period query string true "Конец периода (MM/YYYY)" Example(12/2023)
-// @Success 200 {object} map[string]int "Пример: {\"total_cost\": 150}"
-// @Failure 400 {object} map[string]string "Пример: {\"error\": \"invalid end_period format, use MM/YYYY\"}"
-// @Failure 500 {object} map[string]string "Пример: {\"error\": \"cost calculation failed\"}"
-// @Router /sub/total-cost [get]
-func (h *Handler) GetTotalCost(c *gin.Context) {
-	const fn = "handler.GetTotalCost"
-	h.logger.Info("context", slog.String("fn", fn))
+// costQuery reads the period and filters shared by the cost endpoints.
+func costQuery(c *gin.Context) (model.CostQuery, error) {
+	var query model.CostQuery
 
-	userIDStr := c.Query("user_id")
-	serviceName := c.Query("service_name")
 	startPeriodStr := c.Query("start_period")
 	endPeriodStr := c.Query("end_period")
 
 	if startPeriodStr == "" || endPeriodStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "start_period and end_period are required"})
-		return
+		return query, model.Validationf("start_period and end_period are required")
 	}
 
-	startPeriod, err := time.Parse("01/2006", startPeriodStr)
+	startPeriod, err := parseMonth(startPeriodStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_period format, use MM/YYYY"})
-		return
+		return query, model.Validationf("invalid start_period format, use MM/YYYY")
 	}
 
-	endPeriod, err := time.Parse("01/2006", endPeriodStr)
+	endPeriod, err := parseMonth(endPeriodStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end_period format, use MM/YYYY"})
-		return
+		return query, model.Validationf("invalid end_period format, use MM/YYYY")
 	}
 
-	startPeriod = time.Date(startPeriod.Year(), startPeriod.Month(), 1, 0, 0, 0, 0, time.UTC)
-	endPeriod = time.Date(endPeriod.Year(), endPeriod.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+	query.PeriodStart = startPeriod
+	query.PeriodEnd = endOfMonth(endPeriod)
+	query.ServiceName = stringQuery(c, "service_name")
+	query.TargetCurrency = c.Query("target_currency")
 
-	var userIDPtr *uuid.UUID
-	if userIDStr != "" {
-		userID, err := uuid.Parse(userIDStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id format"})
-			return
-		}
-		userIDPtr = &userID
+	if query.UserID, err = uuidQuery(c, "user_id"); err != nil {
+		return query, err
+	}
+	if query.IncludeDeleted, err = includeDeletedQuery(c); err != nil {
+		return query, err
 	}
 
-	var serviceNamePtr *string
-	if serviceName != "" {
-		serviceNamePtr = &serviceName
+	return query, nil
+}
+
+// GetTotalCost
+// @Summary Расчет общей стоимости
+// @Description Рассчитывает общую стоимость списаний по подпискам за период и среднюю стоимость в месяц
+// @Tags subscriptions
+// @Produce json
+// @Param user_id query string false "ID пользователя (UUID)"
+// @Param service_name query string false "Название сервиса"
+// @Param start_period query string true "Начало периода (MM/YYYY)" Example(01/2023)
+// @Param end_period query string true "Конец периода (MM/YYYY)" Example(12/2023)
+// @Param include_deleted query bool false "Учитывать удаленные подписки"
+// @Param target_currency query string false "Валюта итоговой суммы (ISO 4217)" default(RUB)
+// @Param proration query string false "Учет неполных периодов: none - списания целиком, daily - пропорционально дням" Enums(none, daily) default(none)
+// @Success 200 {object} map[string]interface{} "Пример: {\"total_cost\": 9350.5, \"monthly_equivalent\": 779.21, \"currency\": \"RUB\", \"subtotals\": {\"RUB\": 150, \"USD\": 100}}"
+// @Failure 400 {object} handler.Problem "invalid end_period format, use MM/YYYY"
+// @Failure 422 {object} handler.Problem "start period cannot be after end period"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /sub/total-cost [get]
+func (h *Handler) GetTotalCost(c *gin.Context) {
+	const fn = "handler.GetTotalCost"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	query, err := costQuery(c)
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
-	total, err := h.service.TotalSubscriptionCost(
-		c.Request.Context(),
-		userIDPtr,
-		serviceNamePtr,
-		startPeriod,
-		endPeriod,
-	)
+	query.Proration = c.Query("proration")
 
+	total, err := h.service.TotalSubscriptionCost(c.Request.Context(), query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"total_cost": total})
+	c.JSON(http.StatusOK, gin.H{
+		"total_cost":         total.Total,
+		"monthly_equivalent": total.MonthlyEquivalent,
+		"currency":           total.Currency,
+		"subtotals":          total.Subtotals,
+	})
 	return
 }
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/repository"
+	"github.com/rezexell/em-test-task/internal/service"
+	"github.com/rezexell/em-test-task/internal/testdb"
+)
+
+// dryRunRouter serves the API over a database that runs no queries, with
+// callers authenticated by X-User-ID.
+func dryRunRouter(t *testing.T) http.Handler {
+	t.Helper()
+	services := service.NewService(repository.NewRepository(testdb.DryRun(t).DB))
+	return NewHandler(services, Options{DevAuth: true}, discardLogger).InitRouter()
+}
+
+func TestGetAllSubsRejectsInvalidCursor(t *testing.T) {
+	router := dryRunRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/sub/?order=asc", nil)
+	req.Header.Set(userIDHeader, uuid.NewString())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("without cursor: status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	startDateCursor, _ := json.Marshal(map[string]any{"s": "start_date", "d": true, "v": "2024-01-01", "id": uuid.New()})
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"garbage", "?cursor=garbage"},
+		{"issued for another sort key", "?sort=monthly_cost&cursor=" + base64.RawURLEncoding.EncodeToString(startDateCursor)},
+		{"issued for another direction", "?order=asc&cursor=" + base64.RawURLEncoding.EncodeToString(startDateCursor)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/sub/"+tt.query, nil)
+			req.Header.Set(userIDHeader, uuid.NewString())
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != problemContentType {
+				t.Errorf("Content-Type = %q, want %q", ct, problemContentType)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+)
+
+// CreateWebhook
+// @Summary Зарегистрировать вебхук
+// @Description Регистрирует адрес для событий подписок. Тело запроса подписывается HMAC-SHA256 секретом вебхука в заголовке X-Signature-256 (sha256=<hex>). Если секрет не передан, он генерируется и возвращается только в этом ответе. Пустой список events означает все события
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param input body model.Webhook true "Вебхук"
+// @Success 201 {object} map[string]interface{} "Пример: {\"id\": \"...\", \"url\": \"https://billing.local/hooks\", \"events\": [], \"active\": true, \"secret\": \"...\"}"
+// @Failure 400 {object} handler.Problem "invalid webhook"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /webhooks [post]
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	const fn = "handler.CreateWebhook"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	var webhook model.Webhook
+	if err := c.ShouldBindJSON(&webhook); err != nil {
+		c.Error(model.Validationf("%v", err))
+		return
+	}
+
+	if err := h.service.CreateWebhook(c.Request.Context(), &webhook); err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := webhook.ToResponse()
+	response["secret"] = webhook.Secret
+	c.JSON(http.StatusCreated, response)
+	return
+}
+
+// GetWebhooks
+// @Summary Список вебхуков
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} model.Webhook
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /webhooks [get]
+func (h *Handler) GetWebhooks(c *gin.Context) {
+	const fn = "handler.GetWebhooks"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	webhooks, err := h.service.ListWebhooks(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := make([]gin.H, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		response = append(response, webhook.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, response)
+	return
+}
+
+// GetWebhook
+// @Summary Получить вебхук
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "ID вебхука (UUID)"
+// @Success 200 {object} model.Webhook
+// @Failure 400 {object} handler.Problem "invalid UUID format"
+// @Failure 404 {object} handler.Problem "webhook not found"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /webhooks/{id} [get]
+func (h *Handler) GetWebhook(c *gin.Context) {
+	const fn = "handler.GetWebhook"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(model.Validationf("invalid UUID format"))
+		return
+	}
+
+	webhook, err := h.service.GetWebhook(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook.ToResponse())
+	return
+}
+
+// UpdateWebhook
+// @Summary Обновить вебхук
+// @Description Заменяет адрес, события и признак активности. Секрет меняется, только если передан
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "ID вебхука (UUID)"
+// @Param input body model.Webhook true "Вебхук"
+// @Success 200 {object} model.Webhook
+// @Failure 400 {object} handler.Problem "invalid UUID format"
+// @Failure 404 {object} handler.Problem "webhook not found"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /webhooks/{id} [put]
+func (h *Handler) UpdateWebhook(c *gin.Context) {
+	const fn = "handler.UpdateWebhook"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(model.Validationf("invalid UUID format"))
+		return
+	}
+
+	var webhook model.Webhook
+	if err := c.ShouldBindJSON(&webhook); err != nil {
+		c.Error(model.Validationf("%v", err))
+		return
+	}
+	webhook.ID = id
+
+	updated, err := h.service.UpdateWebhook(c.Request.Context(), &webhook)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated.ToResponse())
+	return
+}
+
+// DeleteWebhook
+// @Summary Удалить вебхук
+// @Description Удаляет вебхук вместе с неотправленными событиями
+// @Tags webhooks
+// @Param id path string true "ID вебхука (UUID)"
+// @Success 204
+// @Failure 400 {object} handler.Problem "invalid UUID format"
+// @Failure 404 {object} handler.Problem "webhook not found"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /webhooks/{id} [delete]
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	const fn = "handler.DeleteWebhook"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(model.Validationf("invalid UUID format"))
+		return
+	}
+
+	if err := h.service.DeleteWebhook(c.Request.Context(), id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+	return
+}
+
+// GetDeadLetters
+// @Summary Недоставленные события
+// @Description Возвращает события, доставка которых не удалась после всех попыток, от новых к старым
+// @Tags webhooks
+// @Produce json
+// @Param webhook_id query string false "ID вебхука (UUID)"
+// @Param limit query int false "Количество (1-500)" default(50)
+// @Success 200 {array} map[string]interface{}
+// @Failure 400 {object} handler.Problem "invalid webhook_id format"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /webhooks/dead-letters [get]
+func (h *Handler) GetDeadLetters(c *gin.Context) {
+	const fn = "handler.GetDeadLetters"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	webhookID, err := uuidQuery(c, "webhook_id")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	limit, err := limitQuery(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	deliveries, err := h.service.ListDeadLetters(c.Request.Context(), webhookID, limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := make([]gin.H, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		response = append(response, delivery.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, response)
+	return
+}
+
+// RetryDeadLetter
+// @Summary Повторить доставку
+// @Description Возвращает недоставленное событие в очередь с обнуленным счетчиком попыток
+// @Tags webhooks
+// @Param id path int true "ID события"
+// @Success 202
+// @Failure 400 {object} handler.Problem "invalid delivery ID"
+// @Failure 404 {object} handler.Problem "delivery 1 not found"
+// @Failure 409 {object} handler.Problem "delivery 1 is not a dead letter"
+// @Failure 500 {object} handler.Problem "internal server error"
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /webhooks/dead-letters/{id}/retry [post]
+func (h *Handler) RetryDeadLetter(c *gin.Context) {
+	const fn = "handler.RetryDeadLetter"
+	h.logger.InfoContext(c.Request.Context(), "context", slog.String("fn", fn))
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(model.Validationf("invalid delivery ID"))
+		return
+	}
+
+	if err := h.service.RetryDeadLetter(c.Request.Context(), id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+	return
+}
@@ -0,0 +1,146 @@
+// Package metrics exposes Prometheus metrics of the HTTP server, the
+// database and the subscriptions.
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rezexell/em-test-task/internal/model"
+	"gorm.io/gorm"
+)
+
+const namespace = "subscriptions"
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	dbDuration   *prometheus.HistogramVec
+
+	activeSubscriptions *prometheus.GaugeVec
+	monthlySpend        *prometheus.GaugeVec
+	kpiRefreshed        prometheus.Gauge
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Database query latency by operation and table.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "table", "status"}),
+		activeSubscriptions: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active",
+			Help:      "Subscriptions active today by service and currency.",
+		}, []string{"service", "currency"}),
+		monthlySpend: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "monthly_spend",
+			Help:      "Current prices of active subscriptions normalized to a month, by service and currency.",
+		}, []string{"service", "currency"}),
+		kpiRefreshed: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "kpi_last_refresh_timestamp_seconds",
+			Help:      "Time of the last successful refresh of the subscription gauges.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.dbDuration,
+		m.activeSubscriptions,
+		m.monthlySpend,
+		m.kpiRefreshed,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveRequest records a served HTTP request. route is the route pattern,
+// not the path, to keep the number of series bounded.
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// InstrumentDB records the duration of every query of db and exposes the
+// statistics of its connection pool.
+func (m *Metrics) InstrumentDB(db *gorm.DB, name string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err := m.registry.Register(collectors.NewDBStatsCollector(sqlDB, name)); err != nil {
+		return err
+	}
+	return db.Use(&gormPlugin{duration: m.dbDuration})
+}
+
+// RunKPIs refreshes the subscription gauges from stats every interval until
+// ctx is canceled.
+func (m *Metrics) RunKPIs(ctx context.Context, stats func(ctx context.Context, day time.Time) ([]*model.ServiceStats, error), interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.refreshKPIs(ctx, stats); err != nil {
+			logger.Error("refreshing subscription metrics failed", slog.Any("err", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Metrics) refreshKPIs(ctx context.Context, stats func(ctx context.Context, day time.Time) ([]*model.ServiceStats, error)) error {
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	rows, err := stats(ctx, today)
+	if err != nil {
+		return err
+	}
+
+	// Services without active subscriptions are dropped rather than kept
+	// at their last value.
+	m.activeSubscriptions.Reset()
+	m.monthlySpend.Reset()
+	for _, row := range rows {
+		m.activeSubscriptions.WithLabelValues(row.ServiceName, row.Currency).Set(float64(row.Active))
+		m.monthlySpend.WithLabelValues(row.ServiceName, row.Currency).Set(row.MonthlySpend)
+	}
+	m.kpiRefreshed.SetToCurrentTime()
+	return nil
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// API key scopes.
+const (
+	ScopeSubsRead    = "subs:read"
+	ScopeSubsWrite   = "subs:write"
+	ScopeReportsRead = "reports:read"
+	ScopeAdmin       = "admin"
+)
+
+// APIKeyPrefix starts every API key, so leaked keys are easy to recognize.
+const APIKeyPrefix = "sk_"
+
+// APIKey authenticates a service. Only the SHA-256 hash of the key is
+// stored; Prefix keeps its first characters to tell keys apart. A key bound
+// to UserID acts as that user, a key without one acts as an admin limited
+// by its scopes.
+// @Description API key
+type APIKey struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Name       string     `gorm:"type:text;not null" json:"name" binding:"required,max=100"`
+	Prefix     string     `gorm:"type:text;not null" json:"-"`
+	Hash       string     `gorm:"type:text;not null" json:"-"`
+	Scopes     []string   `gorm:"type:jsonb;not null;serializer:json" json:"scopes" binding:"required,min=1,dive,oneof=subs:read subs:write reports:read admin"`
+	UserID     *uuid.UUID `gorm:"type:uuid" json:"user_id,omitempty"`
+	CreatedAt  time.Time  `gorm:"type:timestamptz;not null;default:now()" json:"-"`
+	LastUsedAt *time.Time `gorm:"type:timestamptz" json:"-"`
+	RevokedAt  *time.Time `gorm:"type:timestamptz" json:"-"`
+}
+
+// Principal returns the caller authenticated with the key.
+func (k *APIKey) Principal() *Principal {
+	id := k.ID
+	principal := &Principal{
+		Subject:  "api-key:" + k.Name,
+		UserID:   k.UserID,
+		APIKeyID: &id,
+		Scopes:   k.Scopes,
+	}
+	if k.UserID == nil {
+		principal.Roles = []string{RoleAdmin}
+	}
+	return principal
+}
+
+// ToResponse leaves out the key, which is only returned on creation.
+func (k *APIKey) ToResponse() gin.H {
+	return gin.H{
+		"id":           k.ID,
+		"name":         k.Name,
+		"prefix":       k.Prefix,
+		"scopes":       k.Scopes,
+		"user_id":      k.UserID,
+		"created_at":   k.CreatedAt,
+		"last_used_at": k.LastUsedAt,
+		"revoked_at":   k.RevokedAt,
+	}
+}
@@ -0,0 +1,145 @@
+package model
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Roles of a caller. Callers without a known role are users.
+const (
+	RoleAdmin   = "admin"
+	RoleAnalyst = "analyst"
+	RoleUser    = "user"
+)
+
+// RolePolicy is what a role may do. Actions are named like API key scopes.
+// AllUsers lets the role act on subscriptions of every user, not only its
+// own.
+type RolePolicy struct {
+	Actions  []string
+	AllUsers bool
+}
+
+// Policies maps roles to the actions they allow. Admins may do everything,
+// analysts may read subscriptions and reports of every user, and users may
+// read and change their own subscriptions.
+var Policies = map[string]RolePolicy{
+	RoleAdmin: {
+		Actions:  []string{ScopeSubsRead, ScopeSubsWrite, ScopeReportsRead, ScopeAdmin},
+		AllUsers: true,
+	},
+	RoleAnalyst: {
+		Actions:  []string{ScopeSubsRead, ScopeReportsRead},
+		AllUsers: true,
+	},
+	RoleUser: {
+		Actions: []string{ScopeSubsRead, ScopeSubsWrite, ScopeReportsRead},
+	},
+}
+
+// Principal is the authenticated caller. UserID is the user whose
+// subscriptions the caller owns; it is nil for callers without one, such as
+// service accounts. APIKeyID and Scopes are set for callers authenticated
+// with an API key and are nil for everyone else.
+type Principal struct {
+	Subject  string
+	UserID   *uuid.UUID
+	Roles    []string
+	APIKeyID *uuid.UUID
+	Scopes   []string
+}
+
+// Actor names the caller in the change history: its subject, else its API
+// key, else its user.
+func (p *Principal) Actor() string {
+	switch {
+	case p.Subject != "":
+		return p.Subject
+	case p.APIKeyID != nil:
+		return "api-key:" + p.APIKeyID.String()
+	case p.UserID != nil:
+		return "user:" + p.UserID.String()
+	default:
+		return AnonymousActor
+	}
+}
+
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+// policies returns the policies of the caller's known roles, or the user
+// policy when there are none.
+func (p *Principal) policies() []RolePolicy {
+	var policies []RolePolicy
+	for _, role := range p.Roles {
+		if policy, ok := Policies[role]; ok {
+			policies = append(policies, policy)
+		}
+	}
+	if len(policies) == 0 {
+		policies = append(policies, Policies[RoleUser])
+	}
+	return policies
+}
+
+// RoleName returns the roles of the caller for messages.
+func (p *Principal) RoleName() string {
+	var names []string
+	for _, role := range p.Roles {
+		if _, ok := Policies[role]; ok {
+			names = append(names, role)
+		}
+	}
+	if len(names) == 0 {
+		return RoleUser
+	}
+	return strings.Join(names, ", ")
+}
+
+// Allows reports whether a role of the caller allows action.
+func (p *Principal) Allows(action string) bool {
+	for _, policy := range p.policies() {
+		if slices.Contains(policy.Actions, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether the caller's API key, if any, grants scope.
+func (p *Principal) HasScope(scope string) bool {
+	return p.Scopes == nil || slices.Contains(p.Scopes, scope)
+}
+
+// AllUsers reports whether the caller may act on subscriptions of every
+// user.
+func (p *Principal) AllUsers() bool {
+	for _, policy := range p.policies() {
+		if policy.AllUsers {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccess reports whether the caller may act on subscriptions of userID.
+func (p *Principal) CanAccess(userID uuid.UUID) bool {
+	return p.AllUsers() || (p.UserID != nil && *p.UserID == userID)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the authenticated caller.
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the caller stored by WithPrincipal.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
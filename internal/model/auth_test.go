@@ -0,0 +1,29 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPrincipalActor(t *testing.T) {
+	keyID := uuid.MustParse("0b6c7d2e-9f43-4c1a-8e5b-3d2f1a0c9b87")
+	userID := uuid.MustParse("60601fee-2bf1-4721-ae6f-7636e79a0cba")
+	tests := []struct {
+		name      string
+		principal Principal
+		want      string
+	}{
+		{"subject", Principal{Subject: "ops", APIKeyID: &keyID, UserID: &userID}, "ops"},
+		{"api key without subject", Principal{APIKeyID: &keyID, UserID: &userID}, "api-key:" + keyID.String()},
+		{"user without subject", Principal{UserID: &userID}, "user:" + userID.String()},
+		{"nobody", Principal{}, AnonymousActor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.principal.Actor(); got != tt.want {
+				t.Errorf("Actor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
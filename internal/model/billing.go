@@ -0,0 +1,47 @@
+package model
+
+import (
+	"math"
+)
+
+// Billing periods of a subscription.
+const (
+	BillingWeekly    = "weekly"
+	BillingMonthly   = "monthly"
+	BillingQuarterly = "quarterly"
+	BillingYearly    = "yearly"
+)
+
+func IsBillingPeriod(period string) bool {
+	switch period {
+	case BillingWeekly, BillingMonthly, BillingQuarterly, BillingYearly:
+		return true
+	}
+	return false
+}
+
+// MonthlyEquivalent normalizes a price charged every period to a monthly amount.
+func MonthlyEquivalent(price float64, period string) float64 {
+	switch period {
+	case BillingWeekly:
+		return price * 52 / 12
+	case BillingQuarterly:
+		return price / 3
+	case BillingYearly:
+		return price / 12
+	default:
+		return price
+	}
+}
+
+// normalizeBilling fills in defaults for clients that only send monthly_cost
+// and derives MonthlyCost from Price and BillingPeriod.
+func (s *Subscription) normalizeBilling() {
+	if s.BillingPeriod == "" {
+		s.BillingPeriod = BillingMonthly
+	}
+	if s.Price == 0 {
+		s.Price = s.MonthlyCost
+	}
+	s.MonthlyCost = int(math.Round(MonthlyEquivalent(float64(s.Price), s.BillingPeriod)))
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// BaseCurrency is the currency exchange rates are quoted in.
+const BaseCurrency = "RUB"
+
+var supportedCurrencies = map[string]bool{
+	"RUB": true,
+	"USD": true,
+	"EUR": true,
+	"GBP": true,
+	"CNY": true,
+}
+
+func IsSupportedCurrency(code string) bool {
+	return supportedCurrencies[code]
+}
+
+// NormalizeCurrency upper-cases an ISO 4217 code and defaults it to BaseCurrency.
+func NormalizeCurrency(code string) string {
+	if code == "" {
+		return BaseCurrency
+	}
+	return strings.ToUpper(code)
+}
+
+// ExchangeRate is the price of one unit of Currency in BaseCurrency.
+type ExchangeRate struct {
+	Currency  string    `gorm:"type:char(3);primaryKey" json:"currency" binding:"required,currency"`
+	Rate      float64   `gorm:"type:numeric(18,8);not null" json:"rate" binding:"required,gt=0"`
+	UpdatedAt time.Time `gorm:"type:timestamptz;not null;default:now()" json:"updated_at"`
+}
+
+// CostTotal is a total cost converted to Currency together with the
+// unconverted subtotals per subscription currency. MonthlyEquivalent is the
+// total spread evenly over the months of the requested period.
+type CostTotal struct {
+	Currency          string
+	Total             float64
+	MonthlyEquivalent float64
+	Subtotals         map[string]float64
+}
+
+// ConvertCosts converts subtotals to target using rates keyed by currency.
+// The base currency does not need a rate.
+func ConvertCosts(subtotals map[string]float64, rates map[string]float64, target string) (*CostTotal, error) {
+	rateOf := func(currency string) (float64, error) {
+		if currency == BaseCurrency {
+			return 1, nil
+		}
+		rate, ok := rates[currency]
+		if !ok {
+			return 0, Validationf("no exchange rate for %s", currency)
+		}
+		return rate, nil
+	}
+
+	targetRate, err := rateOf(target)
+	if err != nil {
+		return nil, err
+	}
+
+	total := 0.0
+	for currency, amount := range subtotals {
+		rate, err := rateOf(currency)
+		if err != nil {
+			return nil, err
+		}
+		total += amount * rate / targetRate
+	}
+
+	return &CostTotal{
+		Currency:  target,
+		Total:     math.Round(total*100) / 100,
+		Subtotals: subtotals,
+	}, nil
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds shared by the repository, service and handler layers.
+// Match them with errors.Is.
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrValidation      = errors.New("validation failed")
+	ErrConflict        = errors.New("conflict")
+	ErrInvalidPeriod   = errors.New("invalid period")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrTooManyRequests = errors.New("too many requests")
+)
+
+// Error is a domain error of a given kind with a human readable detail.
+type Error struct {
+	Kind   error
+	Detail string
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func NotFoundf(format string, args ...any) error {
+	return &Error{Kind: ErrNotFound, Detail: fmt.Sprintf(format, args...)}
+}
+
+func Validationf(format string, args ...any) error {
+	return &Error{Kind: ErrValidation, Detail: fmt.Sprintf(format, args...)}
+}
+
+func Conflictf(format string, args ...any) error {
+	return &Error{Kind: ErrConflict, Detail: fmt.Sprintf(format, args...)}
+}
+
+func InvalidPeriodf(format string, args ...any) error {
+	return &Error{Kind: ErrInvalidPeriod, Detail: fmt.Sprintf(format, args...)}
+}
+
+func Unauthorizedf(format string, args ...any) error {
+	return &Error{Kind: ErrUnauthorized, Detail: fmt.Sprintf(format, args...)}
+}
+
+func Forbiddenf(format string, args ...any) error {
+	return &Error{Kind: ErrForbidden, Detail: fmt.Sprintf(format, args...)}
+}
+
+func TooManyRequestsf(format string, args ...any) error {
+	return &Error{Kind: ErrTooManyRequests, Detail: fmt.Sprintf(format, args...)}
+}
+
+var (
+	ErrSubscriptionNotFound = NotFoundf("subscription not found")
+	ErrEndBeforeStart       = InvalidPeriodf("end_date cannot be before start_date")
+	ErrWebhookNotFound      = NotFoundf("webhook not found")
+)
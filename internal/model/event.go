@@ -0,0 +1,73 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Subscription event actions.
+const (
+	EventCreated  = "created"
+	EventUpdated  = "updated"
+	EventDeleted  = "deleted"
+	EventRestored = "restored"
+)
+
+const AnonymousActor = "anonymous"
+
+// SystemActor performs changes made by the service itself.
+const SystemActor = "system"
+
+// SubscriptionEvent is a recorded change of a subscription. Before and After
+// hold JSON snapshots of the subscription; Before is nil for creation.
+type SubscriptionEvent struct {
+	ID             int64     `gorm:"primaryKey"`
+	SubscriptionID uuid.UUID `gorm:"type:uuid;not null"`
+	Action         string    `gorm:"type:text;not null"`
+	Before         *string   `gorm:"type:jsonb"`
+	After          *string   `gorm:"type:jsonb"`
+	Actor          string    `gorm:"type:text;not null"`
+	OccurredAt     time.Time `gorm:"type:timestamptz;not null;default:now()"`
+}
+
+func (e *SubscriptionEvent) ToResponse(version int) gin.H {
+	return gin.H{
+		"version":     version,
+		"action":      e.Action,
+		"actor":       e.Actor,
+		"occurred_at": e.OccurredAt,
+		"before":      rawJSON(e.Before),
+		"after":       rawJSON(e.After),
+	}
+}
+
+// State returns the snapshot of the subscription after the event.
+func (e *SubscriptionEvent) State() json.RawMessage {
+	return rawJSON(e.After)
+}
+
+func rawJSON(s *string) json.RawMessage {
+	if s == nil {
+		return nil
+	}
+	return json.RawMessage(*s)
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the name of whoever performs a change.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored by WithActor or AnonymousActor.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SubscriptionFilter describes optional conditions for listing subscriptions.
+// Nil fields are not applied.
+type SubscriptionFilter struct {
+	UserID      *uuid.UUID
+	ServiceName *string
+	// ActiveFrom and ActiveTo select subscriptions that overlap the period.
+	ActiveFrom *time.Time
+	ActiveTo   *time.Time
+	// StartsAfter selects subscriptions starting strictly after the given date.
+	StartsAfter *time.Time
+	// EndsBefore selects subscriptions ending strictly before the given date.
+	EndsBefore *time.Time
+	// OpenEnded selects subscriptions without (true) or with (false) an end date.
+	OpenEnded *bool
+	// IncludeDeleted also selects soft-deleted subscriptions.
+	IncludeDeleted bool
+}
+
+// CostQuery selects the subscriptions whose cost is summed over a period.
+type CostQuery struct {
+	UserID         *uuid.UUID
+	ServiceName    *string
+	PeriodStart    time.Time
+	PeriodEnd      time.Time
+	IncludeDeleted bool
+	// TargetCurrency is the currency the total is converted to.
+	TargetCurrency string
+	// Proration is ProrationNone or ProrationDaily.
+	Proration string
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"github.com/google/uuid"
+)
+
+// Import modes. An atomic import creates either every row or none of them,
+// a partial one creates the valid rows and reports the rest.
+const (
+	ImportAtomic  = "atomic"
+	ImportPartial = "partial"
+)
+
+// MaxImportRows limits the number of rows accepted by one import.
+const MaxImportRows = 5000
+
+// Statuses of an imported row.
+const (
+	ImportCreated = "created"
+	ImportFailed  = "failed"
+	ImportSkipped = "skipped"
+)
+
+// ImportRow is a parsed row of an import file. Err is set when the row could
+// not be parsed or validated and Subscription must not be used.
+type ImportRow struct {
+	Line         int
+	Subscription *Subscription
+	Err          error
+}
+
+// ImportRowResult is the outcome of one row. Skipped rows are valid but were
+// not created because an atomic import failed; their Error says so.
+type ImportRowResult struct {
+	Line   int        `json:"line"`
+	Status string     `json:"status"`
+	ID     *uuid.UUID `json:"id,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
+type ImportReport struct {
+	Mode    string             `json:"mode"`
+	Created int                `json:"created"`
+	Failed  int                `json:"failed"`
+	Rows    []*ImportRowResult `json:"rows"`
+}
+
+// errRolledBack explains why a valid row of a failed atomic import was not
+// created.
+const errRolledBack = "not imported: batch rolled back"
+
+// RollBack marks the rows of a failed atomic import that did not fail
+// themselves as skipped.
+func (r *ImportReport) RollBack() {
+	for _, row := range r.Rows {
+		if row.Status != ImportFailed {
+			row.Status, row.ID, row.Error = ImportSkipped, nil, errRolledBack
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Delivery statuses of an expiry notification. A reminder is reserved as
+// sending before it is sent, so that one whose outcome could not be recorded
+// is not sent again.
+const (
+	NotificationSending = "sending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+)
+
+// MaxNotificationAttempts is how many times a failed reminder is retried.
+const MaxNotificationAttempts = 5
+
+// ExpiryNotification is the delivery state of the reminder that a
+// subscription ends on EndDate, sent through Channel. A new reminder is due
+// when the end date changes.
+type ExpiryNotification struct {
+	ID             int64     `gorm:"primaryKey"`
+	SubscriptionID uuid.UUID `gorm:"type:uuid;not null"`
+	EndDate        time.Time `gorm:"type:date;not null"`
+	Channel        string    `gorm:"type:text;not null"`
+	Status         string    `gorm:"type:text;not null"`
+	Attempts       int       `gorm:"not null;default:0"`
+	LastError      *string   `gorm:"type:text"`
+	SentAt         *time.Time
+	UpdatedAt      time.Time `gorm:"type:timestamptz;not null;default:now()"`
+}
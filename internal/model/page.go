@@ -0,0 +1,44 @@
+package model
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+)
+
+// Sort keys supported by list endpoints.
+const (
+	SortByStartDate   = "start_date"
+	SortByMonthlyCost = "monthly_cost"
+	SortByServiceName = "service_name"
+	SortByCreatedAt   = "created_at"
+)
+
+// PageRequest describes a single page of a keyset-paginated listing.
+// Cursor is the opaque value returned as NextCursor by the previous page.
+type PageRequest struct {
+	Limit  int
+	Cursor string
+	SortBy string
+	Desc   bool
+}
+
+// SubscriptionPage is a page of subscriptions. NextCursor is empty on the
+// last page.
+type SubscriptionPage struct {
+	Items      []*Subscription
+	NextCursor string
+}
+
+func (p PageRequest) Validate() error {
+	if p.Limit < 1 || p.Limit > MaxPageLimit {
+		return Validationf("limit must be between 1 and %d", MaxPageLimit)
+	}
+
+	switch p.SortBy {
+	case SortByStartDate, SortByMonthlyCost, SortByServiceName, SortByCreatedAt:
+	default:
+		return Validationf("unsupported sort key %q", p.SortBy)
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SubscriptionPatch is a JSON Merge Patch (RFC 7396) of a subscription.
+// Nil fields are left untouched; ClearEndDate is set when the patch
+// contains "end_date": null.
+type SubscriptionPatch struct {
+	ServiceName   *string
+	MonthlyCost   *int
+	Price         *int
+	BillingPeriod *string
+	Currency      *string
+	UserID        *uuid.UUID
+	StartDate     *time.Time
+	EndDate       *time.Time
+	ClearEndDate  bool
+}
+
+// ParseSubscriptionPatch decodes and validates a merge patch document.
+// Only the fields present in the document are validated.
+func ParseSubscriptionPatch(data []byte) (*SubscriptionPatch, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, Validationf("patch must be a JSON object")
+	}
+
+	var patch SubscriptionPatch
+	for field, raw := range doc {
+		isNull := string(raw) == "null"
+		if isNull && field != "end_date" {
+			return nil, Validationf("%s cannot be null", field)
+		}
+
+		switch field {
+		case "service_name":
+			var name string
+			if err := json.Unmarshal(raw, &name); err != nil {
+				return nil, Validationf("service_name must be a string")
+			}
+			if len(name) < 2 || len(name) > 255 {
+				return nil, Validationf("service_name must be between 2 and 255 characters")
+			}
+			patch.ServiceName = &name
+		case "monthly_cost":
+			var cost int
+			if err := json.Unmarshal(raw, &cost); err != nil {
+				return nil, Validationf("monthly_cost must be an integer")
+			}
+			if cost <= 0 {
+				return nil, Validationf("monthly_cost must be greater than 0")
+			}
+			patch.MonthlyCost = &cost
+		case "price":
+			var price int
+			if err := json.Unmarshal(raw, &price); err != nil {
+				return nil, Validationf("price must be an integer")
+			}
+			if price <= 0 {
+				return nil, Validationf("price must be greater than 0")
+			}
+			patch.Price = &price
+		case "billing_period":
+			var period string
+			if err := json.Unmarshal(raw, &period); err != nil {
+				return nil, Validationf("billing_period must be a string")
+			}
+			if !IsBillingPeriod(period) {
+				return nil, Validationf("billing_period must be one of weekly, monthly, quarterly, yearly")
+			}
+			patch.BillingPeriod = &period
+		case "currency":
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, Validationf("currency must be a string")
+			}
+			currency := NormalizeCurrency(value)
+			if !IsSupportedCurrency(currency) {
+				return nil, Validationf("unsupported currency %s", value)
+			}
+			patch.Currency = &currency
+		case "user_id":
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, Validationf("user_id must be a string")
+			}
+			userID, err := uuid.Parse(value)
+			if err != nil || userID.Version() != 4 {
+				return nil, Validationf("user_id must be a valid UUIDv4")
+			}
+			patch.UserID = &userID
+		case "start_date":
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, Validationf("start_date must be a string")
+			}
+			startDate, err := parseStartDate(value)
+			if err != nil {
+				return nil, Validationf("invalid start_date format, use YYYY-MM-DD or MM/YYYY")
+			}
+			patch.StartDate = &startDate
+		case "end_date":
+			if isNull {
+				patch.ClearEndDate = true
+				continue
+			}
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, Validationf("end_date must be a string or null")
+			}
+			endDate, err := parseEndDate(value)
+			if err != nil {
+				return nil, Validationf("invalid end_date format, use YYYY-MM-DD or MM/YYYY")
+			}
+			patch.EndDate = &endDate
+		default:
+			return nil, Validationf("unknown field %s", field)
+		}
+	}
+
+	return &patch, nil
+}
+
+// Apply merges the patch into sub and checks the resulting billing and
+// date range. Billing follows the rules of PUT, where monthly_cost is
+// required_without price, applied to the subscription after the patch:
+//   - price is the amount of one charge of the resulting billing_period,
+//     the patched one or else the stored one. PUT defaults it to monthly
+//     instead.
+//   - monthly_cost stands for price only when the resulting billing_period
+//     is monthly, where both are the same amount. PUT ignores monthly_cost
+//     next to price; a patch with both is rejected.
+//   - changing billing_period needs the price of the new period in the
+//     same patch, as price or, for monthly billing, as monthly_cost.
+//   - monthly_cost is then derived from price and billing_period.
+func (p *SubscriptionPatch) Apply(sub *Subscription) error {
+	if p.ServiceName != nil {
+		sub.ServiceName = *p.ServiceName
+	}
+	if p.BillingPeriod != nil {
+		// The stored price is the amount of a charge of the old period.
+		if *p.BillingPeriod != sub.BillingPeriod && p.Price == nil && p.MonthlyCost == nil {
+			return Validationf("billing_period can only be changed together with the price of the new period")
+		}
+		sub.BillingPeriod = *p.BillingPeriod
+	}
+	if p.MonthlyCost != nil {
+		if p.Price != nil {
+			return Validationf("monthly_cost and price cannot be set together")
+		}
+		if sub.BillingPeriod != BillingMonthly {
+			return Validationf("monthly_cost can only be set for monthly billing, set price instead")
+		}
+		sub.Price = *p.MonthlyCost
+	}
+	if p.Price != nil {
+		sub.Price = *p.Price
+	}
+	sub.normalizeBilling()
+	if p.Currency != nil {
+		sub.Currency = *p.Currency
+	}
+	if p.UserID != nil {
+		sub.UserID = *p.UserID
+	}
+	if p.StartDate != nil {
+		sub.StartDate = *p.StartDate
+	}
+	if p.EndDate != nil {
+		sub.EndDate = p.EndDate
+	}
+	if p.ClearEndDate {
+		sub.EndDate = nil
+	}
+
+	if sub.EndDate != nil && sub.EndDate.Before(sub.StartDate) {
+		return ErrEndBeforeStart
+	}
+	return nil
+}
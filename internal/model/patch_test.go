@@ -0,0 +1,235 @@
+package model
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func patchTarget() *Subscription {
+	endDate := date(2024, 12, 31)
+	return &Subscription{
+		ID:            uuid.MustParse("2b1c3f7e-51d4-4b8e-9d6a-0f6f1f0a9c11"),
+		ServiceName:   "Yandex Plus",
+		MonthlyCost:   400,
+		Price:         400,
+		BillingPeriod: BillingMonthly,
+		Currency:      "RUB",
+		UserID:        uuid.MustParse("60601fee-2bf1-4721-ae6f-7636e79a0cba"),
+		StartDate:     date(2024, 7, 1),
+		EndDate:       &endDate,
+	}
+}
+
+func TestSubscriptionPatchApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  func(sub *Subscription)
+	}{
+		{
+			name:  "null clears end_date",
+			patch: `{"end_date": null}`,
+			want:  func(sub *Subscription) { sub.EndDate = nil },
+		},
+		{
+			name:  "absent fields stay untouched",
+			patch: `{"service_name": "Kinopoisk"}`,
+			want:  func(sub *Subscription) { sub.ServiceName = "Kinopoisk" },
+		},
+		{
+			name:  "empty patch changes nothing",
+			patch: `{}`,
+			want:  func(sub *Subscription) {},
+		},
+		{
+			name:  "month end_date is the last day of the month",
+			patch: `{"end_date": "02/2025"}`,
+			want: func(sub *Subscription) {
+				end := date(2025, 2, 28)
+				sub.EndDate = &end
+			},
+		},
+		{
+			name:  "month start_date is the first day of the month",
+			patch: `{"start_date": "09/2024"}`,
+			want:  func(sub *Subscription) { sub.StartDate = date(2024, 9, 1) },
+		},
+		{
+			name:  "full dates are kept as is",
+			patch: `{"start_date": "2024-07-15", "end_date": "2024-08-20"}`,
+			want: func(sub *Subscription) {
+				end := date(2024, 8, 20)
+				sub.StartDate, sub.EndDate = date(2024, 7, 15), &end
+			},
+		},
+		{
+			name:  "monthly_cost sets the price of monthly billing",
+			patch: `{"monthly_cost": 500}`,
+			want:  func(sub *Subscription) { sub.MonthlyCost, sub.Price = 500, 500 },
+		},
+		{
+			name:  "price and period recompute monthly_cost",
+			patch: `{"billing_period": "yearly", "price": 1200}`,
+			want: func(sub *Subscription) {
+				sub.BillingPeriod, sub.Price, sub.MonthlyCost = BillingYearly, 1200, 100
+			},
+		},
+		{
+			name:  "unchanged billing_period needs no price",
+			patch: `{"billing_period": "monthly", "service_name": "Kinopoisk"}`,
+			want:  func(sub *Subscription) { sub.ServiceName = "Kinopoisk" },
+		},
+		{
+			name:  "currency is normalized",
+			patch: `{"currency": "usd"}`,
+			want:  func(sub *Subscription) { sub.Currency = "USD" },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := ParseSubscriptionPatch([]byte(tt.patch))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+
+			got, want := patchTarget(), patchTarget()
+			tt.want(want)
+			if err := patch.Apply(got); err != nil {
+				t.Fatalf("apply: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got  %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseSubscriptionPatchRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+	}{
+		{"not an object", `["end_date"]`},
+		{"malformed json", `{"end_date": `},
+		{"unknown field", `{"id": "2b1c3f7e-51d4-4b8e-9d6a-0f6f1f0a9c11"}`},
+		{"null service_name", `{"service_name": null}`},
+		{"null monthly_cost", `{"monthly_cost": null}`},
+		{"null price", `{"price": null}`},
+		{"null billing_period", `{"billing_period": null}`},
+		{"null currency", `{"currency": null}`},
+		{"null user_id", `{"user_id": null}`},
+		{"null start_date", `{"start_date": null}`},
+		{"invalid start month", `{"start_date": "13/2024"}`},
+		{"invalid end month", `{"end_date": "2024/07"}`},
+		{"month without leading zero", `{"start_date": "7/2024"}`},
+		{"end_date of wrong type", `{"end_date": 202407}`},
+		{"short service_name", `{"service_name": "X"}`},
+		{"zero monthly_cost", `{"monthly_cost": 0}`},
+		{"fractional price", `{"price": 9.99}`},
+		{"unknown billing_period", `{"billing_period": "daily"}`},
+		{"unsupported currency", `{"currency": "XXX"}`},
+		{"user_id that is not UUIDv4", `{"user_id": "00000000-0000-1000-8000-000000000000"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSubscriptionPatch([]byte(tt.patch))
+			if !errors.Is(err, ErrValidation) {
+				t.Fatalf("err = %v, want a validation error", err)
+			}
+		})
+	}
+}
+
+func TestSubscriptionPatchApplyRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  error
+	}{
+		{"end_date before start_date", `{"end_date": "06/2024"}`, ErrInvalidPeriod},
+		{"start_date after end_date", `{"start_date": "2025-01-01"}`, ErrInvalidPeriod},
+		{"monthly_cost with price", `{"monthly_cost": 100, "price": 100}`, ErrValidation},
+		{"monthly_cost for yearly billing", `{"billing_period": "yearly", "monthly_cost": 100}`, ErrValidation},
+		{"billing_period without price", `{"billing_period": "yearly"}`, ErrValidation},
+		{"billing_period with other fields but no price", `{"billing_period": "weekly", "service_name": "Kinopoisk"}`, ErrValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := ParseSubscriptionPatch([]byte(tt.patch))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if err := patch.Apply(patchTarget()); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscriptionPatchBilling(t *testing.T) {
+	monthly := patchTarget
+	yearly := func() *Subscription {
+		sub := patchTarget()
+		sub.BillingPeriod, sub.Price, sub.MonthlyCost = BillingYearly, 1200, 100
+		return sub
+	}
+
+	tests := []struct {
+		name   string
+		stored func() *Subscription
+		patch  string
+		// period, price and monthlyCost are the billing after the patch
+		// unless it is rejected.
+		period      string
+		price       int
+		monthlyCost int
+		rejected    bool
+	}{
+		{name: "monthly_cost of monthly billing", stored: monthly, patch: `{"monthly_cost": 500}`, period: BillingMonthly, price: 500, monthlyCost: 500},
+		{name: "price of monthly billing", stored: monthly, patch: `{"price": 500}`, period: BillingMonthly, price: 500, monthlyCost: 500},
+		{name: "monthly to yearly with price", stored: monthly, patch: `{"billing_period": "yearly", "price": 1200}`, period: BillingYearly, price: 1200, monthlyCost: 100},
+		{name: "monthly to weekly with price", stored: monthly, patch: `{"billing_period": "weekly", "price": 120}`, period: BillingWeekly, price: 120, monthlyCost: 520},
+		{name: "monthly to yearly with monthly_cost", stored: monthly, patch: `{"billing_period": "yearly", "monthly_cost": 100}`, rejected: true},
+		{name: "monthly to yearly without price", stored: monthly, patch: `{"billing_period": "yearly"}`, rejected: true},
+		{name: "price and monthly_cost", stored: monthly, patch: `{"price": 500, "monthly_cost": 500}`, rejected: true},
+		{name: "same billing_period", stored: monthly, patch: `{"billing_period": "monthly"}`, period: BillingMonthly, price: 400, monthlyCost: 400},
+		{name: "price of yearly billing", stored: yearly, patch: `{"price": 2400}`, period: BillingYearly, price: 2400, monthlyCost: 200},
+		{name: "monthly_cost of stored yearly billing", stored: yearly, patch: `{"monthly_cost": 200}`, rejected: true},
+		{name: "yearly to monthly with monthly_cost", stored: yearly, patch: `{"billing_period": "monthly", "monthly_cost": 300}`, period: BillingMonthly, price: 300, monthlyCost: 300},
+		{name: "yearly to monthly with price", stored: yearly, patch: `{"billing_period": "monthly", "price": 300}`, period: BillingMonthly, price: 300, monthlyCost: 300},
+		{name: "yearly to quarterly without price", stored: yearly, patch: `{"billing_period": "quarterly"}`, rejected: true},
+		{name: "unrelated fields keep yearly billing", stored: yearly, patch: `{"service_name": "Kinopoisk"}`, period: BillingYearly, price: 1200, monthlyCost: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := ParseSubscriptionPatch([]byte(tt.patch))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+
+			sub := tt.stored()
+			err = patch.Apply(sub)
+			if tt.rejected {
+				if !errors.Is(err, ErrValidation) {
+					t.Fatalf("err = %v, want a validation error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("apply: %v", err)
+			}
+			if sub.BillingPeriod != tt.period || sub.Price != tt.price || sub.MonthlyCost != tt.monthlyCost {
+				t.Errorf("billing = %s %d (monthly %d), want %s %d (monthly %d)",
+					sub.BillingPeriod, sub.Price, sub.MonthlyCost, tt.period, tt.price, tt.monthlyCost)
+			}
+		})
+	}
+}
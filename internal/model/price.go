@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SubscriptionPrice is a change of a subscription's price starting from
+// EffectiveFrom. Scheduled changes start on the first of a month; editing
+// the price of a subscription records the old price from its start date and
+// the new one from the day of the edit. Charges before the first change are
+// made at Subscription.Price.
+type SubscriptionPrice struct {
+	ID               int64     `gorm:"primaryKey" json:"-"`
+	SubscriptionID   uuid.UUID `gorm:"type:uuid;not null" json:"-"`
+	EffectiveFrom    time.Time `gorm:"type:date;not null" json:"-"`
+	Price            int       `gorm:"not null;check:price>0" json:"price" binding:"required,gt=0"`
+	CreatedAt        time.Time `gorm:"type:timestamptz;not null;default:now()" json:"-"`
+	EffectiveFromStr string    `gorm:"-" json:"effective_from" binding:"required,datetime=01/2006"`
+}
+
+func (p *SubscriptionPrice) AfterBind() error {
+	effectiveFrom, err := parseStartMonth(p.EffectiveFromStr)
+	if err != nil {
+		return err
+	}
+	p.EffectiveFrom = effectiveFrom
+	return nil
+}
+
+func (p *SubscriptionPrice) ToResponse() gin.H {
+	return gin.H{
+		"subscription_id": p.SubscriptionID,
+		"effective_from":  p.EffectiveFrom,
+		"price":           p.Price,
+		"created_at":      p.CreatedAt,
+	}
+}
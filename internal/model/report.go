@@ -0,0 +1,29 @@
+package model
+
+import (
+	"github.com/google/uuid"
+)
+
+// Cost breakdown dimensions.
+const (
+	GroupByMonth   = "month"
+	GroupByService = "service"
+	GroupByUser    = "user"
+)
+
+// Cost proration modes. Without proration every charge made within the period
+// counts in full; daily proration counts the share of each charge's billing
+// period that falls within the period and the subscription's dates.
+const (
+	ProrationNone  = "none"
+	ProrationDaily = "daily"
+)
+
+// CostBreakdownRow is the cost of one group. Dimensions not grouped by are nil.
+type CostBreakdownRow struct {
+	Month       *string    `json:"month,omitempty"`
+	ServiceName *string    `json:"service_name,omitempty"`
+	UserID      *uuid.UUID `json:"user_id,omitempty"`
+	Currency    string     `json:"currency"`
+	TotalCost   int        `json:"total_cost"`
+}
@@ -0,0 +1,10 @@
+package model
+
+// ServiceStats summarizes the subscriptions of a service active on a day.
+// MonthlySpend is the sum of their current prices normalized to a month.
+type ServiceStats struct {
+	ServiceName  string
+	Currency     string
+	Active       int64
+	MonthlySpend float64
+}
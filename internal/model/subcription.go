@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,63 +13,132 @@ import (
 // Subscription represents a user's subscription
 // @Description Subscription information
 type Subscription struct {
-	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	ServiceName  string     `gorm:"type:text;not null" json:"service_name" binding:"required,min=2,max=255"`
-	MonthlyCost  int        `gorm:"not null;check:monthly_cost>0" json:"monthly_cost" binding:"required,gt=0"`
-	UserID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id" binding:"required,uuid4"`
-	StartDate    time.Time  `gorm:"type:date;not null" json:"-"`
-	EndDate      *time.Time `gorm:"type:date" json:"-"`
-	StartDateStr string     `gorm:"-" json:"start_date" binding:"required,datetime=01/2006"`
-	EndDateStr   string     `gorm:"-" json:"end_date,omitempty" binding:"omitempty,datetime=01/2006"`
+	ID            uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ServiceName   string         `gorm:"type:text;not null" json:"service_name" binding:"required,min=2,max=255"`
+	MonthlyCost   int            `gorm:"not null;check:monthly_cost>=0" json:"monthly_cost" binding:"required_without=Price,omitempty,gt=0"`
+	Price         int            `gorm:"not null;check:price>0" json:"price,omitempty" binding:"required_without=MonthlyCost,omitempty,gt=0"`
+	BillingPeriod string         `gorm:"type:text;not null;default:monthly" json:"billing_period,omitempty" binding:"omitempty,oneof=weekly monthly quarterly yearly"`
+	Currency      string         `gorm:"type:char(3);not null;default:RUB" json:"currency,omitempty" binding:"omitempty,currency"`
+	UserID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id" binding:"required,uuid4"`
+	StartDate     time.Time      `gorm:"type:date;not null" json:"-"`
+	EndDate       *time.Time     `gorm:"type:date" json:"-"`
+	StartDateStr  string         `gorm:"-" json:"start_date" binding:"required,subdate"`
+	EndDateStr    string         `gorm:"-" json:"end_date,omitempty" binding:"omitempty,subdate"`
+	CreatedAt     time.Time      `gorm:"type:timestamptz;not null;default:now()" json:"-"`
+	UpdatedAt     time.Time      `gorm:"type:timestamptz;not null;default:now()" json:"-"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (s *Subscription) AfterBind() error {
-	startDate, err := time.Parse("01/2006", s.StartDateStr)
+	s.Currency = NormalizeCurrency(s.Currency)
+	s.normalizeBilling()
+
+	startDate, err := parseStartDate(s.StartDateStr)
 	if err != nil {
 		return err
 	}
-	s.StartDate = time.Date(startDate.Year(), startDate.Month(), 1, 0, 0, 0, 0, time.UTC)
+	s.StartDate = startDate
 
 	if s.EndDateStr != "" {
-		endDate, err := time.Parse("01/2006", s.EndDateStr)
+		endDate, err := parseEndDate(s.EndDateStr)
 		if err != nil {
 			return err
 		}
-		firstOfNextMonth := time.Date(endDate.Year(), endDate.Month()+1, 1, 0, 0, 0, 0, time.UTC)
-		lastDay := firstOfNextMonth.AddDate(0, 0, -1)
-		s.EndDate = &lastDay
+		s.EndDate = &endDate
 	}
 	return nil
 }
 
+// Accepted formats of subscription dates. A full date is taken as is, a
+// month stands for its first day as a start and its last day as an end.
+const (
+	dateLayout  = "2006-01-02"
+	monthLayout = "01/2006"
+)
+
+// parseStartDate parses a YYYY-MM-DD or MM/YYYY start date.
+func parseStartDate(value string) (time.Time, error) {
+	if day, err := time.Parse(dateLayout, value); err == nil {
+		return day, nil
+	}
+	return parseStartMonth(value)
+}
+
+// parseEndDate parses a YYYY-MM-DD or MM/YYYY end date.
+func parseEndDate(value string) (time.Time, error) {
+	if day, err := time.Parse(dateLayout, value); err == nil {
+		return day, nil
+	}
+	return parseEndMonth(value)
+}
+
+// parseStartMonth parses a MM/YYYY value into the first day of that month.
+func parseStartMonth(value string) (time.Time, error) {
+	month, err := time.Parse(monthLayout, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+// parseEndMonth parses a MM/YYYY value into the last day of that month.
+func parseEndMonth(value string) (time.Time, error) {
+	month, err := time.Parse(monthLayout, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	firstOfNextMonth := time.Date(month.Year(), month.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	return firstOfNextMonth.AddDate(0, 0, -1), nil
+}
+
 func (s *Subscription) ToResponse() gin.H {
 	response := gin.H{
-		"id":           s.ID,
-		"service_name": s.ServiceName,
-		"monthly_cost": s.MonthlyCost,
-		"user_id":      s.UserID,
-		"start_date":   s.StartDate,
+		"id":             s.ID,
+		"service_name":   s.ServiceName,
+		"monthly_cost":   s.MonthlyCost,
+		"price":          s.Price,
+		"billing_period": s.BillingPeriod,
+		"currency":       s.Currency,
+		"user_id":        s.UserID,
+		"start_date":     s.StartDate,
+		"created_at":     s.CreatedAt,
+		"updated_at":     s.UpdatedAt,
 	}
 
 	if s.EndDate != nil {
 		response["end_date"] = s.EndDate
 	}
 
+	if s.DeletedAt.Valid {
+		response["deleted_at"] = s.DeletedAt.Time
+	}
+
 	return response
 }
 
 func RegisterCustomBindings() {
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		v.RegisterStructValidation(SubscriptionStructLevelValidation, Subscription{})
+		_ = v.RegisterValidation("currency", func(fl validator.FieldLevel) bool {
+			return IsSupportedCurrency(NormalizeCurrency(fl.Field().String()))
+		})
+		_ = v.RegisterValidation("subdate", func(fl validator.FieldLevel) bool {
+			_, err := parseStartDate(fl.Field().String())
+			return err == nil
+		})
 	}
 }
 
 func SubscriptionStructLevelValidation(sl validator.StructLevel) {
 	sub := sl.Current().Interface().(Subscription)
 
+	if sub.BillingPeriod != "" && sub.BillingPeriod != BillingMonthly && sub.Price == 0 {
+		sl.ReportError(sub.Price, "price", "Price", "required_for_billing_period", sub.BillingPeriod)
+	}
+
 	if sub.EndDateStr != "" {
-		start, err1 := time.Parse("01/2006", sub.StartDateStr)
-		end, err2 := time.Parse("01/2006", sub.EndDateStr)
+		start, err1 := parseStartDate(sub.StartDateStr)
+		end, err2 := parseEndDate(sub.EndDateStr)
 
 		if err1 == nil && err2 == nil && end.Before(start) {
 			sl.ReportError(sub.EndDateStr, "end_date", "EndDate", "end_before_start", "")
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Webhook events.
+const (
+	WebhookSubscriptionCreated  = "subscription.created"
+	WebhookSubscriptionUpdated  = "subscription.updated"
+	WebhookSubscriptionDeleted  = "subscription.deleted"
+	WebhookSubscriptionRestored = "subscription.restored"
+	WebhookSubscriptionExpired  = "subscription.expired"
+)
+
+// WebhookEventFor maps a subscription event action to its webhook event.
+func WebhookEventFor(action string) string {
+	return "subscription." + action
+}
+
+// Delivery statuses of a webhook event.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// MaxDeliveryAttempts is how many times an event is sent before it is moved
+// to the dead letters.
+const MaxDeliveryAttempts = 8
+
+// Webhook is an endpoint receiving subscription events. An empty Events
+// list subscribes to every event.
+// @Description Webhook endpoint
+type Webhook struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	URL       string    `gorm:"type:text;not null" json:"url" binding:"required,url"`
+	Secret    string    `gorm:"type:text;not null" json:"secret,omitempty" binding:"omitempty,min=16"`
+	Events    []string  `gorm:"type:jsonb;not null;serializer:json" json:"events" binding:"dive,oneof=subscription.created subscription.updated subscription.deleted subscription.restored subscription.expired"`
+	Active    *bool     `gorm:"not null;default:true" json:"active,omitempty"`
+	CreatedAt time.Time `gorm:"type:timestamptz;not null;default:now()" json:"-"`
+	UpdatedAt time.Time `gorm:"type:timestamptz;not null;default:now()" json:"-"`
+}
+
+// ToResponse leaves out the secret, which is only returned on creation.
+func (w *Webhook) ToResponse() gin.H {
+	events := w.Events
+	if events == nil {
+		events = []string{}
+	}
+	return gin.H{
+		"id":         w.ID,
+		"url":        w.URL,
+		"events":     events,
+		"active":     w.Active == nil || *w.Active,
+		"created_at": w.CreatedAt,
+		"updated_at": w.UpdatedAt,
+	}
+}
+
+// WebhookDelivery is an event queued for a webhook in the outbox.
+type WebhookDelivery struct {
+	ID             int64     `gorm:"primaryKey"`
+	WebhookID      uuid.UUID `gorm:"type:uuid;not null"`
+	Webhook        *Webhook
+	SubscriptionID uuid.UUID `gorm:"type:uuid;not null"`
+	Event          string    `gorm:"type:text;not null"`
+	Payload        string    `gorm:"type:jsonb;not null"`
+	DedupeKey      *string   `gorm:"type:text"`
+	Status         string    `gorm:"type:text;not null;default:pending"`
+	Attempts       int       `gorm:"not null;default:0"`
+	NextAttemptAt  time.Time `gorm:"type:timestamptz;not null;default:now()"`
+	LastError      *string   `gorm:"type:text"`
+	CreatedAt      time.Time `gorm:"type:timestamptz;not null;default:now()"`
+	DeliveredAt    *time.Time
+}
+
+func (d *WebhookDelivery) ToResponse() gin.H {
+	return gin.H{
+		"id":              d.ID,
+		"webhook_id":      d.WebhookID,
+		"subscription_id": d.SubscriptionID,
+		"event":           d.Event,
+		"payload":         rawJSON(&d.Payload),
+		"status":          d.Status,
+		"attempts":        d.Attempts,
+		"last_error":      d.LastError,
+		"created_at":      d.CreatedAt,
+		"next_attempt_at": d.NextAttemptAt,
+	}
+}
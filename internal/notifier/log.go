@@ -0,0 +1,33 @@
+package notifier
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/rezexell/em-test-task/internal/model"
+)
+
+// Log writes reminders to the application log.
+type Log struct {
+	logger *slog.Logger
+}
+
+func NewLog(logger *slog.Logger) *Log {
+	return &Log{logger: logger}
+}
+
+func (n *Log) Channel() string {
+	return ChannelLog
+}
+
+func (n *Log) NotifyExpiry(ctx context.Context, sub *model.Subscription) error {
+	n.logger.WarnContext(ctx, "subscription expires soon",
+		slog.String("id", sub.ID.String()),
+		slog.String("service_name", sub.ServiceName),
+		slog.String("user_id", sub.UserID.String()),
+		slog.String("end_date", sub.EndDate.Format(time.DateOnly)),
+		slog.Int("days_left", daysLeft(sub, time.Now())),
+	)
+	return nil
+}
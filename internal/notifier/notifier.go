@@ -0,0 +1,65 @@
+// Package notifier delivers reminders about subscriptions that are about to
+// end.
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rezexell/em-test-task/internal/config"
+	"github.com/rezexell/em-test-task/internal/model"
+)
+
+// Notifier channels.
+const (
+	ChannelLog     = "log"
+	ChannelWebhook = "webhook"
+	ChannelSMTP    = "smtp"
+)
+
+// Notifier sends a reminder that sub ends on its EndDate.
+type Notifier interface {
+	// Channel names the notifier in the persisted delivery state.
+	Channel() string
+	NotifyExpiry(ctx context.Context, sub *model.Subscription) error
+}
+
+// New builds the notifier selected in cfg. It returns nil when reminders are
+// disabled.
+func New(cfg *config.Config, logger *slog.Logger) (Notifier, error) {
+	switch cfg.NOTIFIER {
+	case "":
+		return nil, nil
+	case ChannelLog:
+		return NewLog(logger), nil
+	case ChannelWebhook:
+		if cfg.WEBHOOKURL == "" {
+			return nil, fmt.Errorf("NOTIFY_WEBHOOK_URL is required for the webhook notifier")
+		}
+		return NewWebhook(cfg.WEBHOOKURL, 10*time.Second), nil
+	case ChannelSMTP:
+		if cfg.SMTPHOST == "" || cfg.SMTPFROM == "" || cfg.SMTPTO == "" {
+			return nil, fmt.Errorf("SMTP_HOST, SMTP_FROM and SMTP_TO are required for the smtp notifier")
+		}
+		return NewSMTP(SMTPConfig{
+			Addr:     net.JoinHostPort(cfg.SMTPHOST, strconv.Itoa(cfg.SMTPPORT)),
+			Username: cfg.SMTPUSER,
+			Password: cfg.SMTPPASSWORD,
+			From:     cfg.SMTPFROM,
+			To:       strings.Split(cfg.SMTPTO, ","),
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %q", cfg.NOTIFIER)
+	}
+}
+
+// daysLeft counts whole days from the start of today to the end date.
+func daysLeft(sub *model.Subscription, now time.Time) int {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return int(sub.EndDate.Sub(today).Hours() / 24)
+}
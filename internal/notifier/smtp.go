@@ -0,0 +1,77 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/rezexell/em-test-task/internal/model"
+)
+
+type SMTPConfig struct {
+	// Addr is the host:port of the mail server.
+	Addr string
+	// Username and Password enable PLAIN authentication when set. net/smtp
+	// only sends them over TLS or to localhost.
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// SMTP mails reminders to a fixed list of recipients.
+type SMTP struct {
+	cfg SMTPConfig
+}
+
+func NewSMTP(cfg SMTPConfig) *SMTP {
+	for i := range cfg.To {
+		cfg.To[i] = strings.TrimSpace(cfg.To[i])
+	}
+	return &SMTP{cfg: cfg}
+}
+
+func (n *SMTP) Channel() string {
+	return ChannelSMTP
+}
+
+// NotifyExpiry sends the mail. net/smtp does not take a context, so ctx is
+// only checked before connecting.
+func (n *SMTP) NotifyExpiry(ctx context.Context, sub *model.Subscription) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if n.cfg.Username != "" {
+		host, _, err := net.SplitHostPort(n.cfg.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, host)
+	}
+
+	return smtp.SendMail(n.cfg.Addr, auth, n.cfg.From, n.cfg.To, n.message(sub, time.Now()))
+}
+
+func (n *SMTP) message(sub *model.Subscription, now time.Time) []byte {
+	endDate := sub.EndDate.Format(time.DateOnly)
+	subject := fmt.Sprintf("Subscription %s ends on %s", sub.ServiceName, endDate)
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "Subscription %s to %s of user %s ends on %s (in %d days).\r\n",
+		sub.ID, sub.ServiceName, sub.UserID, endDate, daysLeft(sub, now))
+
+	return msg.Bytes()
+}
@@ -0,0 +1,175 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"mime"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+)
+
+// mail is what the mock SMTP server received in one session.
+type mail struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// mockSMTP accepts one session on a local port. reject answers the RCPT
+// command of that address with an error.
+func mockSMTP(t *testing.T, reject string) (string, <-chan mail) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	mails := make(chan mail, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		text := textproto.NewConn(conn)
+		var m mail
+		text.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			command, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(command) {
+			case "EHLO":
+				text.PrintfLine("250-localhost")
+				text.PrintfLine("250 AUTH PLAIN")
+			case "AUTH":
+				_, credentials, _ := strings.Cut(arg, " ")
+				decoded, _ := base64.StdEncoding.DecodeString(credentials)
+				m.auth = string(decoded)
+				text.PrintfLine("235 2.7.0 Authentication successful")
+			case "MAIL":
+				m.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+				text.PrintfLine("250 OK")
+			case "RCPT":
+				to := strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+				if to == reject {
+					text.PrintfLine("550 5.1.1 No such user")
+					continue
+				}
+				m.to = append(m.to, to)
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				data, err := text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				m.data = string(data)
+				text.PrintfLine("250 OK")
+			case "QUIT":
+				text.PrintfLine("221 Bye")
+				mails <- m
+				return
+			default:
+				text.PrintfLine("502 Command not implemented")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), mails
+}
+
+func expiringSub() *model.Subscription {
+	endDate := time.Now().UTC().AddDate(0, 0, 3)
+	return &model.Subscription{
+		ID:          uuid.New(),
+		ServiceName: "Yandex Plus",
+		UserID:      uuid.New(),
+		EndDate:     &endDate,
+	}
+}
+
+func TestSMTPNotifyExpiry(t *testing.T) {
+	addr, mails := mockSMTP(t, "")
+	n := NewSMTP(SMTPConfig{
+		Addr:     addr,
+		Username: "bot",
+		Password: "secret",
+		From:     "subscriptions@example.com",
+		To:       []string{"ops@example.com", " billing@example.com"},
+	})
+	sub := expiringSub()
+
+	if err := n.NotifyExpiry(context.Background(), sub); err != nil {
+		t.Fatal(err)
+	}
+
+	var m mail
+	select {
+	case m = <-mails:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail received")
+	}
+	if m.auth != "\x00bot\x00secret" {
+		t.Errorf("auth = %q", m.auth)
+	}
+	if m.from != "subscriptions@example.com" {
+		t.Errorf("from = %q", m.from)
+	}
+	if strings.Join(m.to, ",") != "ops@example.com,billing@example.com" {
+		t.Errorf("to = %q", m.to)
+	}
+
+	msg, err := textproto.NewReader(bufio.NewReader(strings.NewReader(m.data))).ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("message %q: %v", m.data, err)
+	}
+	endDate := sub.EndDate.Format(time.DateOnly)
+	for key, want := range map[string]string{
+		"From":         "subscriptions@example.com",
+		"To":           "ops@example.com, billing@example.com",
+		"Subject":      "Subscription Yandex Plus ends on " + endDate,
+		"Content-Type": "text/plain; charset=utf-8",
+	} {
+		got, err := new(mime.WordDecoder).DecodeHeader(msg.Get(key))
+		if err != nil || got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if !strings.Contains(m.data, sub.ID.String()) || !strings.Contains(m.data, "ends on "+endDate+" (in 3 days)") {
+		t.Errorf("body does not describe the subscription: %q", m.data)
+	}
+}
+
+func TestSMTPNotifyExpiryRejectedRecipient(t *testing.T) {
+	addr, _ := mockSMTP(t, "nobody@example.com")
+	n := NewSMTP(SMTPConfig{Addr: addr, From: "subscriptions@example.com", To: []string{"nobody@example.com"}})
+
+	err := n.NotifyExpiry(context.Background(), expiringSub())
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Fatalf("err = %v, want the 550 reply", err)
+	}
+}
+
+func TestSMTPNotifyExpiryCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n := NewSMTP(SMTPConfig{Addr: "127.0.0.1:1", From: "subscriptions@example.com", To: []string{"ops@example.com"}})
+
+	if err := n.NotifyExpiry(ctx, expiringSub()); err != context.Canceled {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,57 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rezexell/em-test-task/internal/model"
+)
+
+// Webhook posts reminders as JSON to a URL. Any non-2xx response is a
+// failed delivery.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhook(url string, timeout time.Duration) *Webhook {
+	return &Webhook{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (n *Webhook) Channel() string {
+	return ChannelWebhook
+}
+
+func (n *Webhook) NotifyExpiry(ctx context.Context, sub *model.Subscription) error {
+	body, err := json.Marshal(map[string]any{
+		"event":        "subscription.expiring",
+		"days_left":    daysLeft(sub, time.Now()),
+		"subscription": sub.ToResponse(),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+	"gorm.io/gorm"
+)
+
+type APIKeyPostgres struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyPostgres(db *gorm.DB) *APIKeyPostgres {
+	return &APIKeyPostgres{db: db}
+}
+
+func (r *APIKeyPostgres) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *APIKeyPostgres) ListAPIKeys(ctx context.Context) ([]*model.APIKey, error) {
+	var keys []*model.APIKey
+	if err := r.db.WithContext(ctx).Order("created_at, id").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey disables a key. Revoking a revoked key keeps the original
+// revocation time.
+func (r *APIKeyPostgres) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Model(&model.APIKey{}).
+		Where("id = ?", id).
+		Update("revoked_at", gorm.Expr("COALESCE(revoked_at, now())"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return model.NotFoundf("api key %s not found", id)
+	}
+	return nil
+}
+
+// GetActiveAPIKey returns the key with the given hash unless it is revoked.
+func (r *APIKeyPostgres) GetActiveAPIKey(ctx context.Context, hash string) (*model.APIKey, error) {
+	var key model.APIKey
+	result := r.db.WithContext(ctx).Where("hash = ? AND revoked_at IS NULL", hash).First(&key)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, model.Unauthorizedf("invalid api key")
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &key, nil
+}
+
+// TouchAPIKey records the use of a key. The timestamp is only written when
+// the previous one is older than precision, to spare a write per request.
+func (r *APIKeyPostgres) TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time, precision time.Duration) error {
+	return r.db.WithContext(ctx).Model(&model.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, at.Add(-precision)).
+		Update("last_used_at", at).Error
+}
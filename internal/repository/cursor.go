@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+)
+
+var ErrInvalidCursor = model.Validationf("invalid cursor")
+
+// cursor points at the last row of a page. It remembers the sort it was
+// issued for so it cannot be replayed against a different ordering.
+type cursor struct {
+	SortBy string    `json:"s"`
+	Desc   bool      `json:"d"`
+	Value  string    `json:"v"`
+	ID     uuid.UUID `json:"id"`
+}
+
+func newCursor(page model.PageRequest, sub *model.Subscription) cursor {
+	c := cursor{SortBy: page.SortBy, Desc: page.Desc, ID: sub.ID}
+
+	switch page.SortBy {
+	case model.SortByStartDate:
+		c.Value = sub.StartDate.Format(time.DateOnly)
+	case model.SortByMonthlyCost:
+		c.Value = strconv.Itoa(sub.MonthlyCost)
+	case model.SortByServiceName:
+		c.Value = sub.ServiceName
+	case model.SortByCreatedAt:
+		c.Value = sub.CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	return c
+}
+
+func (c cursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string, page model.PageRequest) (cursor, error) {
+	var c cursor
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	if c.SortBy != page.SortBy || c.Desc != page.Desc {
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// sortValue converts the cursor value back to the type of the sort column.
+func (c cursor) sortValue() (any, error) {
+	var (
+		value any
+		err   error
+	)
+
+	switch c.SortBy {
+	case model.SortByStartDate:
+		value, err = time.Parse(time.DateOnly, c.Value)
+	case model.SortByMonthlyCost:
+		value, err = strconv.Atoi(c.Value)
+	case model.SortByServiceName:
+		value = c.Value
+	case model.SortByCreatedAt:
+		value, err = time.Parse(time.RFC3339Nano, c.Value)
+	default:
+		err = ErrInvalidCursor
+	}
+
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return value, nil
+}
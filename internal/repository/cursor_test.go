@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2024, 3, 5, 10, 11, 12, 123456000, time.FixedZone("MSK", 3*60*60))
+	sub := &model.Subscription{
+		ID:          uuid.New(),
+		ServiceName: "Yandex Plus",
+		MonthlyCost: 399,
+		StartDate:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		CreatedAt:   createdAt,
+	}
+
+	tests := []struct {
+		sortBy string
+		want   any
+	}{
+		{model.SortByStartDate, sub.StartDate},
+		{model.SortByMonthlyCost, sub.MonthlyCost},
+		{model.SortByServiceName, sub.ServiceName},
+		{model.SortByCreatedAt, createdAt},
+	}
+	for _, tt := range tests {
+		for _, desc := range []bool{false, true} {
+			page := model.PageRequest{Limit: 10, SortBy: tt.sortBy, Desc: desc}
+
+			decoded, err := decodeCursor(newCursor(page, sub).encode(), page)
+			if err != nil {
+				t.Fatalf("%s desc=%v: decode: %v", tt.sortBy, desc, err)
+			}
+			if decoded.ID != sub.ID {
+				t.Errorf("%s desc=%v: ID = %s, want %s", tt.sortBy, desc, decoded.ID, sub.ID)
+			}
+
+			value, err := decoded.sortValue()
+			if err != nil {
+				t.Fatalf("%s desc=%v: sortValue: %v", tt.sortBy, desc, err)
+			}
+			if want, ok := tt.want.(time.Time); ok {
+				if got, _ := value.(time.Time); !got.Equal(want) {
+					t.Errorf("%s desc=%v: value = %v, want %v", tt.sortBy, desc, value, want)
+				}
+			} else if value != tt.want {
+				t.Errorf("%s desc=%v: value = %v, want %v", tt.sortBy, desc, value, tt.want)
+			}
+		}
+	}
+}
+
+func TestDecodeCursorRejectsInvalidCursors(t *testing.T) {
+	sub := &model.Subscription{ID: uuid.New(), StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	byStartDate := model.PageRequest{Limit: 10, SortBy: model.SortByStartDate, Desc: true}
+	issued := newCursor(byStartDate, sub).encode()
+
+	tests := []struct {
+		name   string
+		cursor string
+		page   model.PageRequest
+	}{
+		{"not base64", "!!!", byStartDate},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("start_date")), byStartDate},
+		{"other sort key", issued, model.PageRequest{Limit: 10, SortBy: model.SortByMonthlyCost, Desc: true}},
+		{"other direction", issued, model.PageRequest{Limit: 10, SortBy: model.SortByStartDate}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCursor(tt.cursor, tt.page)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("err = %v, want ErrInvalidCursor", err)
+			}
+			if !errors.Is(err, model.ErrValidation) {
+				t.Error("ErrInvalidCursor is not a validation error")
+			}
+		})
+	}
+}
+
+func TestCursorSortValueRejectsMalformedValue(t *testing.T) {
+	for _, sortBy := range []string{model.SortByStartDate, model.SortByMonthlyCost, model.SortByCreatedAt, "id"} {
+		c := cursor{SortBy: sortBy, Value: "not-a-value"}
+		if _, err := c.sortValue(); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: err = %v, want ErrInvalidCursor", sortBy, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type HistoryPostgres struct {
+	db *gorm.DB
+}
+
+func NewHistoryPostgres(db *gorm.DB) *HistoryPostgres {
+	return &HistoryPostgres{db: db}
+}
+
+func (r *HistoryPostgres) ListEvents(ctx context.Context, subscriptionID uuid.UUID) ([]*model.SubscriptionEvent, error) {
+	var events []*model.SubscriptionEvent
+	result := r.db.WithContext(ctx).
+		Where("subscription_id = ?", subscriptionID).
+		Order("occurred_at, id").
+		Find(&events)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return events, nil
+}
+
+// snapshot locks the subscription row, including a soft-deleted one, and
+// returns its JSON representation or nil when the row does not exist.
+func snapshot(tx *gorm.DB, id uuid.UUID) (*string, error) {
+	var sub model.Subscription
+	result := tx.Unscoped().
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).
+		Take(&sub)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	raw, err := json.Marshal(sub.ToResponse())
+	if err != nil {
+		return nil, err
+	}
+	state := string(raw)
+	return &state, nil
+}
+
+// recordEvent stores the change of a subscription made in tx, taking the
+// current row as the after state, and queues it for webhooks.
+func recordEvent(tx *gorm.DB, id uuid.UUID, action string, before *string) error {
+	after, err := snapshot(tx, id)
+	if err != nil {
+		return err
+	}
+
+	event := &model.SubscriptionEvent{
+		SubscriptionID: id,
+		Action:         action,
+		Before:         before,
+		After:          after,
+		Actor:          model.ActorFromContext(tx.Statement.Context),
+	}
+	if err := tx.Create(event).Error; err != nil {
+		return err
+	}
+
+	return enqueueWebhooks(tx, event)
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/rezexell/em-test-task/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationPostgres struct {
+	db *gorm.DB
+}
+
+func NewNotificationPostgres(db *gorm.DB) *NotificationPostgres {
+	return &NotificationPostgres{db: db}
+}
+
+// ListExpiring returns subscriptions ending between from and to, both
+// inclusive, that still need a reminder through channel: none was sent or
+// is being sent for their current end date and the retries are not
+// exhausted.
+func (r *NotificationPostgres) ListExpiring(ctx context.Context, from, to time.Time, channel string) ([]*model.Subscription, error) {
+	var subscriptions []*model.Subscription
+	result := r.db.WithContext(ctx).
+		Where("end_date BETWEEN ? AND ?", from, to).
+		Where(`NOT EXISTS (
+			SELECT 1 FROM expiry_notifications n
+			WHERE n.subscription_id = subscriptions.id AND n.end_date = subscriptions.end_date AND n.channel = ?
+				AND (n.status <> ? OR n.attempts >= ?)
+		)`, channel, model.NotificationFailed, model.MaxNotificationAttempts).
+		Order("end_date, id").
+		Find(&subscriptions)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return subscriptions, nil
+}
+
+// ReserveNotification marks the reminder as sending and counts the attempt
+// before it is sent. It reports false, changing nothing, when the reminder
+// was already sent, is being sent or has no attempts left. The unique key
+// on (subscription_id, end_date, channel) makes concurrent reservations of
+// one reminder fail but one.
+func (r *NotificationPostgres) ReserveNotification(ctx context.Context, n *model.ExpiryNotification) (bool, error) {
+	n.Status = model.NotificationSending
+	n.Attempts = 1
+	n.UpdatedAt = time.Now()
+
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "subscription_id"}, {Name: "end_date"}, {Name: "channel"}},
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Eq{Column: "expiry_notifications.status", Value: model.NotificationFailed},
+				clause.Lt{Column: "expiry_notifications.attempts", Value: model.MaxNotificationAttempts},
+			}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"status":     n.Status,
+				"attempts":   gorm.Expr("expiry_notifications.attempts + 1"),
+				"updated_at": n.UpdatedAt,
+			}),
+		}).
+		Create(n)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// SaveNotification records the outcome of a reserved reminder.
+func (r *NotificationPostgres) SaveNotification(ctx context.Context, n *model.ExpiryNotification) error {
+	n.UpdatedAt = time.Now()
+
+	return r.db.WithContext(ctx).
+		Model(&model.ExpiryNotification{}).
+		Where("subscription_id = ? AND end_date = ? AND channel = ?", n.SubscriptionID, n.EndDate, n.Channel).
+		Updates(map[string]any{
+			"status":     n.Status,
+			"last_error": n.LastError,
+			"sent_at":    n.SentAt,
+			"updated_at": n.UpdatedAt,
+		}).Error
+}
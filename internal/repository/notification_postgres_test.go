@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/internal/testdb"
+)
+
+func TestReserveNotification(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewNotificationPostgres(db)
+	ctx := context.Background()
+
+	endDate := time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC)
+	sub := &model.Subscription{
+		ServiceName:   "Netflix",
+		MonthlyCost:   100,
+		Price:         100,
+		BillingPeriod: model.BillingMonthly,
+		Currency:      "RUB",
+		UserID:        uuid.New(),
+		StartDate:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:       &endDate,
+	}
+	if err := NewSubPostgres(db).Create(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+	reminder := func(status string) *model.ExpiryNotification {
+		return &model.ExpiryNotification{SubscriptionID: sub.ID, EndDate: endDate, Channel: "smtp", Status: status}
+	}
+	reserve := func(want bool) {
+		t.Helper()
+		reserved, err := repo.ReserveNotification(ctx, reminder(""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reserved != want {
+			t.Fatalf("reserved = %v, want %v", reserved, want)
+		}
+	}
+	save := func(status string) {
+		t.Helper()
+		if err := repo.SaveNotification(ctx, reminder(status)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	due := func(want bool) {
+		t.Helper()
+		subs, err := repo.ListExpiring(ctx, endDate, endDate, "smtp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (len(subs) == 1) != want {
+			t.Fatalf("listed %d subscriptions, want due %v", len(subs), want)
+		}
+	}
+
+	due(true)
+	reserve(true)
+	// An unsaved outcome leaves the reminder reserved.
+	due(false)
+	reserve(false)
+
+	for attempt := 2; attempt <= model.MaxNotificationAttempts; attempt++ {
+		save(model.NotificationFailed)
+		due(true)
+		reserve(true)
+	}
+	save(model.NotificationFailed)
+	due(false)
+	reserve(false)
+
+	var n model.ExpiryNotification
+	if err := db.Where("subscription_id = ?", sub.ID).First(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n.Attempts != model.MaxNotificationAttempts || n.Status != model.NotificationFailed {
+		t.Errorf("attempts %d, status %s", n.Attempts, n.Status)
+	}
+
+	// Another channel is reserved separately and a sent reminder is final.
+	other := reminder("")
+	other.Channel = "webhook"
+	if reserved, err := repo.ReserveNotification(ctx, other); err != nil || !reserved {
+		t.Fatalf("webhook reminder: reserved %v, err %v", reserved, err)
+	}
+	other.Status = model.NotificationSent
+	if err := repo.SaveNotification(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	if reserved, err := repo.ReserveNotification(ctx, other); err != nil || reserved {
+		t.Fatalf("sent webhook reminder: reserved %v, err %v", reserved, err)
+	}
+}
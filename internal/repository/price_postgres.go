@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+	"gorm.io/gorm"
+)
+
+type PricePostgres struct {
+	db *gorm.DB
+}
+
+func NewPricePostgres(db *gorm.DB) *PricePostgres {
+	return &PricePostgres{db: db}
+}
+
+func (r *PricePostgres) CreatePrice(ctx context.Context, price *model.SubscriptionPrice) error {
+	result := r.db.WithContext(ctx).Create(price)
+	if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+		return model.Conflictf("price change effective from %s already scheduled",
+			price.EffectiveFrom.Format("01/2006"))
+	}
+	if errors.Is(result.Error, gorm.ErrForeignKeyViolated) {
+		return model.ErrSubscriptionNotFound
+	}
+	return result.Error
+}
+
+// ListPrices returns the price changes of the given subscriptions ordered by
+// subscription and effective month.
+func (r *PricePostgres) ListPrices(ctx context.Context, subscriptionIDs ...uuid.UUID) ([]*model.SubscriptionPrice, error) {
+	var prices []*model.SubscriptionPrice
+	if len(subscriptionIDs) == 0 {
+		return prices, nil
+	}
+
+	result := r.db.WithContext(ctx).
+		Where("subscription_id IN ?", subscriptionIDs).
+		Order("subscription_id, effective_from").
+		Find(&prices)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return prices, nil
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/rezexell/em-test-task/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RatePostgres struct {
+	db *gorm.DB
+}
+
+func NewRatePostgres(db *gorm.DB) *RatePostgres {
+	return &RatePostgres{db: db}
+}
+
+// UpsertRates inserts new rates and overwrites existing ones in one statement.
+func (r *RatePostgres) UpsertRates(ctx context.Context, rates []*model.ExchangeRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for _, rate := range rates {
+		rate.UpdatedAt = now
+	}
+
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "currency"}},
+			DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"}),
+		}).
+		Create(&rates).Error
+}
+
+func (r *RatePostgres) ListRates(ctx context.Context) ([]*model.ExchangeRate, error) {
+	var rates []*model.ExchangeRate
+	if err := r.db.WithContext(ctx).Order("currency").Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
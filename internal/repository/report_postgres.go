@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/internal/tracing"
+	"gorm.io/gorm"
+)
+
+type ReportPostgres struct {
+	db *gorm.DB
+}
+
+func NewReportPostgres(db *gorm.DB) *ReportPostgres {
+	return &ReportPostgres{db: db}
+}
+
+var breakdownColumns = map[string]struct{ sel, group string }{
+	model.GroupByMonth:   {sel: "to_char(date_trunc('month', c.charge_date), 'MM/YYYY') AS month", group: "date_trunc('month', c.charge_date)"},
+	model.GroupByService: {sel: "s.service_name", group: "s.service_name"},
+	model.GroupByUser:    {sel: "s.user_id", group: "s.user_id"},
+}
+
+// TotalCost sums the charges of matching subscriptions made within the
+// period per subscription currency. With daily proration a charge counts
+// by the share of days of its billing period that are within both the
+// query period and the subscription's dates.
+func (r *ReportPostgres) TotalCost(ctx context.Context, query model.CostQuery) (_ map[string]float64, err error) {
+	ctx, span := tracer.Start(ctx, "ReportPostgres.TotalCost")
+	defer tracing.End(span, &err)
+
+	var rows []struct {
+		Currency  string
+		TotalCost float64
+	}
+
+	db := charges(r.db.WithContext(ctx), query)
+	if query.Proration == model.ProrationDaily {
+		db = db.Select(`s.currency, ROUND(SUM(COALESCE(p.price, s.price)
+				* (LEAST(c.next_charge_date, b.last_day + 1) - GREATEST(c.charge_date, CAST(? AS date)))::numeric
+				/ (c.next_charge_date - c.charge_date)), 2) AS total_cost`, query.PeriodStart).
+			Where("c.next_charge_date > ?", query.PeriodStart)
+	} else {
+		db = db.Select("s.currency, SUM(COALESCE(p.price, s.price)) AS total_cost").
+			Where("c.charge_date >= ?", query.PeriodStart)
+	}
+
+	if err := db.Group("s.currency").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	subtotals := make(map[string]float64, len(rows))
+	for _, row := range rows {
+		subtotals[row.Currency] = row.TotalCost
+	}
+	return subtotals, nil
+}
+
+// CostBreakdown sums the charges of matching subscriptions made within the
+// period per group and currency. Months are those of the charge dates.
+func (r *ReportPostgres) CostBreakdown(ctx context.Context, query model.CostQuery, groupBy []string) (_ []*model.CostBreakdownRow, err error) {
+	ctx, span := tracer.Start(ctx, "ReportPostgres.CostBreakdown")
+	defer tracing.End(span, &err)
+
+	selects := make([]string, 0, len(groupBy)+2)
+	groups := make([]string, 0, len(groupBy)+1)
+	for _, dimension := range groupBy {
+		column := breakdownColumns[dimension]
+		selects = append(selects, column.sel)
+		groups = append(groups, column.group)
+	}
+	selects = append(selects, "s.currency", "SUM(COALESCE(p.price, s.price)) AS total_cost")
+	groups = append(groups, "s.currency")
+
+	db := charges(r.db.WithContext(ctx), query).
+		Where("c.charge_date >= ?", query.PeriodStart).
+		Select(strings.Join(selects, ", ")).
+		Group(strings.Join(groups, ", ")).
+		Order(strings.Join(groups, ", "))
+
+	var rows []*model.CostBreakdownRow
+	if err := db.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// charges joins matching subscriptions "s" with their charge dates "c" up to
+// the end of the query period, plus the price change "p" valid on each
+// date. The k-th charge of a subscription is made on start_date + k billing
+// periods and covers the days until the next one; "b.last_day" is the last
+// day charges are generated up to. Callers bound the charges from below.
+func charges(db *gorm.DB, query model.CostQuery) *gorm.DB {
+	db = db.Table("subscriptions AS s").
+		Joins("CROSS JOIN LATERAL (SELECT LEAST(s.end_date, CAST(? AS date)) AS last_day) AS b", query.PeriodEnd).
+		Joins(`CROSS JOIN LATERAL generate_series(0, CASE s.billing_period
+				WHEN 'weekly' THEN (b.last_day - s.start_date) / 7
+				ELSE (`+monthIndex("b.last_day")+` - `+monthIndex("s.start_date")+`) / CASE s.billing_period
+					WHEN 'quarterly' THEN 3
+					WHEN 'yearly' THEN 12
+					ELSE 1
+				END
+			END) AS k(n)`).
+		Joins(`CROSS JOIN LATERAL (SELECT CASE s.billing_period
+				WHEN 'weekly' THEN interval '7 days'
+				WHEN 'quarterly' THEN interval '3 months'
+				WHEN 'yearly' THEN interval '1 year'
+				ELSE interval '1 month'
+			END AS step) AS i`).
+		Joins(`CROSS JOIN LATERAL (SELECT
+				(s.start_date + k.n * i.step)::date AS charge_date,
+				(s.start_date + (k.n + 1) * i.step)::date AS next_charge_date
+			) AS c`).
+		Joins(`LEFT JOIN LATERAL (
+				SELECT sp.price FROM subscription_prices sp
+				WHERE sp.subscription_id = s.id AND sp.effective_from <= c.charge_date
+				ORDER BY sp.effective_from DESC
+				LIMIT 1
+			) AS p ON true`).
+		Where("s.start_date <= ?", query.PeriodEnd).
+		Where("s.end_date IS NULL OR s.end_date >= ?", query.PeriodStart).
+		Where("c.charge_date <= b.last_day")
+
+	if query.UserID != nil {
+		db = db.Where("s.user_id = ?", *query.UserID)
+	}
+	if query.ServiceName != nil {
+		db = db.Where("s.service_name = ?", *query.ServiceName)
+	}
+	if !query.IncludeDeleted {
+		db = db.Where("s.deleted_at IS NULL")
+	}
+
+	return db
+}
+
+// monthIndex numbers months continuously, so that the count of months
+// between two dates is a difference of their indexes.
+func monthIndex(expr string) string {
+	return "(EXTRACT(YEAR FROM " + expr + ") * 12 + EXTRACT(MONTH FROM " + expr + "))::int"
+}
+
+// ServiceStats counts the subscriptions active on day per service and
+// currency and sums their prices effective on that day, normalized to a
+// month.
+func (r *ReportPostgres) ServiceStats(ctx context.Context, day time.Time) ([]*model.ServiceStats, error) {
+	var stats []*model.ServiceStats
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT s.service_name, s.currency, COUNT(*) AS active,
+			SUM(CASE s.billing_period
+				WHEN 'weekly' THEN COALESCE(p.price, s.price) * 52 / 12.0
+				WHEN 'quarterly' THEN COALESCE(p.price, s.price) / 3.0
+				WHEN 'yearly' THEN COALESCE(p.price, s.price) / 12.0
+				ELSE COALESCE(p.price, s.price)
+			END) AS monthly_spend
+		FROM subscriptions s
+		LEFT JOIN LATERAL (
+			SELECT sp.price FROM subscription_prices sp
+			WHERE sp.subscription_id = s.id AND sp.effective_from <= CAST(@day AS date)
+			ORDER BY sp.effective_from DESC
+			LIMIT 1
+		) AS p ON true
+		WHERE s.deleted_at IS NULL
+			AND s.start_date <= CAST(@day AS date)
+			AND (s.end_date IS NULL OR s.end_date >= CAST(@day AS date))
+		GROUP BY s.service_name, s.currency`,
+		map[string]any{"day": day}).
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -3,25 +3,96 @@ package repository
 import (
 	"context"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
 	"gorm.io/gorm"
 	"time"
 
 	"github.com/rezexell/em-test-task/internal/model"
 )
 
+var tracer = otel.Tracer("github.com/rezexell/em-test-task/internal/repository")
+
 type Subscription interface {
 	Create(ctx context.Context, sub *model.Subscription) error
+	CreateBatch(ctx context.Context, subs []*model.Subscription, partial bool) ([]error, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error)
+	GetByIDWithDeleted(ctx context.Context, id uuid.UUID) (*model.Subscription, error)
 	Update(ctx context.Context, sub *model.Subscription) error
+	Replace(ctx context.Context, sub *model.Subscription) error
 	Delete(ctx context.Context, id uuid.UUID) error
-	ListAll(ctx context.Context) ([]*model.Subscription, error)
-	ListWithFilters(ctx context.Context, userID *uuid.UUID, serviceName *string, startPeriod, endPeriod *time.Time) ([]*model.Subscription, error)
+	Restore(ctx context.Context, id uuid.UUID) error
+	ListPage(ctx context.Context, filter model.SubscriptionFilter, page model.PageRequest) (*model.SubscriptionPage, error)
+	ListWithFilters(ctx context.Context, filter model.SubscriptionFilter) ([]*model.Subscription, error)
+	Export(ctx context.Context, filter model.SubscriptionFilter, fn func(*model.Subscription) error) error
+}
+
+type History interface {
+	ListEvents(ctx context.Context, subscriptionID uuid.UUID) ([]*model.SubscriptionEvent, error)
+}
+
+type Price interface {
+	CreatePrice(ctx context.Context, price *model.SubscriptionPrice) error
+	ListPrices(ctx context.Context, subscriptionIDs ...uuid.UUID) ([]*model.SubscriptionPrice, error)
+}
+
+type Report interface {
+	TotalCost(ctx context.Context, query model.CostQuery) (map[string]float64, error)
+	CostBreakdown(ctx context.Context, query model.CostQuery, groupBy []string) ([]*model.CostBreakdownRow, error)
+	ServiceStats(ctx context.Context, day time.Time) ([]*model.ServiceStats, error)
+}
+
+type ExchangeRate interface {
+	UpsertRates(ctx context.Context, rates []*model.ExchangeRate) error
+	ListRates(ctx context.Context) ([]*model.ExchangeRate, error)
+}
+
+type Notification interface {
+	ListExpiring(ctx context.Context, from, to time.Time, channel string) ([]*model.Subscription, error)
+	ReserveNotification(ctx context.Context, notification *model.ExpiryNotification) (bool, error)
+	SaveNotification(ctx context.Context, notification *model.ExpiryNotification) error
+}
+
+type Webhook interface {
+	CreateWebhook(ctx context.Context, webhook *model.Webhook) error
+	GetWebhook(ctx context.Context, id uuid.UUID) (*model.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]*model.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook *model.Webhook) error
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	ListDeadLetters(ctx context.Context, webhookID *uuid.UUID, limit int) ([]*model.WebhookDelivery, error)
+	RetryDelivery(ctx context.Context, id int64) error
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*model.WebhookDelivery, error)
+	SaveAttempt(ctx context.Context, delivery *model.WebhookDelivery) error
+	EnqueueExpired(ctx context.Context, today time.Time) error
+}
+
+type APIKey interface {
+	CreateAPIKey(ctx context.Context, key *model.APIKey) error
+	ListAPIKeys(ctx context.Context) ([]*model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id uuid.UUID) error
+	GetActiveAPIKey(ctx context.Context, hash string) (*model.APIKey, error)
+	TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time, precision time.Duration) error
 }
 
 type Repository struct {
 	Subscription
+	History
+	Price
+	Report
+	ExchangeRate
+	Notification
+	Webhook
+	APIKey
 }
 
 func NewRepository(db *gorm.DB) *Repository {
-	return &Repository{Subscription: NewSubPostgres(db)}
+	return &Repository{
+		Subscription: NewSubPostgres(db),
+		History:      NewHistoryPostgres(db),
+		Price:        NewPricePostgres(db),
+		Report:       NewReportPostgres(db),
+		ExchangeRate: NewRatePostgres(db),
+		Notification: NewNotificationPostgres(db),
+		Webhook:      NewWebhookPostgres(db),
+		APIKey:       NewAPIKeyPostgres(db),
+	}
 }
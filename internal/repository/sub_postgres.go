@@ -3,11 +3,14 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/internal/tracing"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type SubPostgres struct {
@@ -18,17 +21,84 @@ func NewSubPostgres(db *gorm.DB) *SubPostgres {
 	return &SubPostgres{db: db}
 }
 
-func (r *SubPostgres) Create(ctx context.Context, sub *model.Subscription) error {
-	result := r.db.WithContext(ctx).Create(sub)
-	return result.Error
+func (r *SubPostgres) Create(ctx context.Context, sub *model.Subscription) (err error) {
+	ctx, span := tracer.Start(ctx, "SubPostgres.Create")
+	defer tracing.End(span, &err)
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Create(sub)
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return model.Conflictf("subscription %s already exists", sub.ID)
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return recordEvent(tx, sub.ID, model.EventCreated, nil)
+	})
+}
+
+// errImportAborted rolls back an atomic import after a row failed.
+var errImportAborted = errors.New("import aborted")
+
+// CreateBatch creates subs in one transaction and returns the error of each
+// row that could not be created. Unless partial is set the first such error
+// rolls back the whole batch. Errors not caused by the row itself abort the
+// batch and are returned as the second result.
+func (r *SubPostgres) CreateBatch(ctx context.Context, subs []*model.Subscription, partial bool) (_ []error, err error) {
+	ctx, span := tracer.Start(ctx, "SubPostgres.CreateBatch")
+	defer tracing.End(span, &err)
+
+	rowErrs := make([]error, len(subs))
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, sub := range subs {
+			if err := tx.SavePoint("import_row").Error; err != nil {
+				return err
+			}
+
+			err := tx.Create(sub).Error
+			switch {
+			case errors.Is(err, gorm.ErrDuplicatedKey):
+				err = model.Conflictf("subscription %s already exists", sub.ID)
+			case errors.Is(err, gorm.ErrCheckConstraintViolated):
+				err = model.Validationf("subscription violates a check constraint")
+			case err == nil:
+				err = recordEvent(tx, sub.ID, model.EventCreated, nil)
+			}
+
+			var domainErr *model.Error
+			if err != nil && !errors.As(err, &domainErr) {
+				return err
+			}
+			if err != nil {
+				rowErrs[i] = err
+				if !partial {
+					return errImportAborted
+				}
+				if err := tx.RollbackTo("import_row").Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportAborted) {
+		return nil, err
+	}
+
+	return rowErrs, nil
 }
 
-func (r *SubPostgres) GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
+func (r *SubPostgres) GetByID(ctx context.Context, id uuid.UUID) (_ *model.Subscription, err error) {
+	ctx, span := tracer.Start(ctx, "SubPostgres.GetByID")
+	defer tracing.End(span, &err)
+
 	var sub model.Subscription
 	result := r.db.WithContext(ctx).Where("id = ?", id).First(&sub)
 
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		return nil, nil
+		return nil, model.ErrSubscriptionNotFound
 	}
 	if result.Error != nil {
 		return nil, result.Error
@@ -37,66 +107,285 @@ func (r *SubPostgres) GetByID(ctx context.Context, id uuid.UUID) (*model.Subscri
 	return &sub, nil
 }
 
-func (r *SubPostgres) Update(ctx context.Context, sub *model.Subscription) error {
-	result := r.db.WithContext(ctx).Model(&model.Subscription{}).
-		Where("id = ?", sub.ID).
-		Updates(sub)
+func (r *SubPostgres) GetByIDWithDeleted(ctx context.Context, id uuid.UUID) (_ *model.Subscription, err error) {
+	ctx, span := tracer.Start(ctx, "SubPostgres.GetByIDWithDeleted")
+	defer tracing.End(span, &err)
 
+	var sub model.Subscription
+	result := r.db.WithContext(ctx).Unscoped().Where("id = ?", id).First(&sub)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, model.ErrSubscriptionNotFound
+	}
 	if result.Error != nil {
-		return result.Error
+		return nil, result.Error
+	}
+
+	return &sub, nil
+}
+
+func (r *SubPostgres) Update(ctx context.Context, sub *model.Subscription) (err error) {
+	ctx, span := tracer.Start(ctx, "SubPostgres.Update")
+	defer tracing.End(span, &err)
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := snapshot(tx, sub.ID)
+		if err != nil {
+			return err
+		}
+		if err := keepPastPrice(tx, sub); err != nil {
+			return err
+		}
+
+		result := tx.Model(&model.Subscription{}).
+			Where("id = ?", sub.ID).
+			Updates(sub)
+
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return model.ErrSubscriptionNotFound
+		}
+
+		return recordEvent(tx, sub.ID, model.EventUpdated, before)
+	})
+}
+
+// Replace writes every mutable column of sub, including zero values and a
+// nil end_date, unlike Update.
+func (r *SubPostgres) Replace(ctx context.Context, sub *model.Subscription) (err error) {
+	ctx, span := tracer.Start(ctx, "SubPostgres.Replace")
+	defer tracing.End(span, &err)
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := snapshot(tx, sub.ID)
+		if err != nil {
+			return err
+		}
+		if err := keepPastPrice(tx, sub); err != nil {
+			return err
+		}
+
+		result := tx.Model(&model.Subscription{}).
+			Where("id = ?", sub.ID).
+			Select("service_name", "monthly_cost", "price", "billing_period", "currency", "user_id", "start_date", "end_date", "updated_at").
+			Updates(sub)
+
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return model.ErrSubscriptionNotFound
+		}
+
+		return recordEvent(tx, sub.ID, model.EventUpdated, before)
+	})
+}
+
+// keepPastPrice records the price charged before an edit of sub's price as
+// a price change from its start date, unless one already starts there, and
+// the new price as a change from today. Charges already made keep their
+// price in reports, while later ones, up to the next scheduled change, use
+// the new one. It needs the row locked by snapshot.
+func keepPastPrice(tx *gorm.DB, sub *model.Subscription) error {
+	var current model.Subscription
+	err := tx.Unscoped().Select("price", "start_date").Where("id = ?", sub.ID).Take(&current).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
 	}
-	if result.RowsAffected == 0 {
-		return errors.New("subscription not found")
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if sub.Price == 0 || sub.Price == current.Price || !current.StartDate.Before(today) {
+		return nil
+	}
+
+	past := &model.SubscriptionPrice{SubscriptionID: sub.ID, EffectiveFrom: current.StartDate, Price: current.Price}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(past).Error; err != nil {
+		return err
 	}
+	next := &model.SubscriptionPrice{SubscriptionID: sub.ID, EffectiveFrom: today, Price: sub.Price}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "subscription_id"}, {Name: "effective_from"}},
+		DoUpdates: clause.AssignmentColumns([]string{"price"}),
+	}).Create(next).Error
+}
+
+func (r *SubPostgres) Delete(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := tracer.Start(ctx, "SubPostgres.Delete")
+	defer tracing.End(span, &err)
 
-	return nil
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := snapshot(tx, id)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Where("id = ?", id).Delete(&model.Subscription{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return model.ErrSubscriptionNotFound
+		}
+
+		return recordEvent(tx, id, model.EventDeleted, before)
+	})
+}
+
+// Restore clears deleted_at of a soft-deleted subscription.
+func (r *SubPostgres) Restore(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := tracer.Start(ctx, "SubPostgres.Restore")
+	defer tracing.End(span, &err)
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := snapshot(tx, id)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().Model(&model.Subscription{}).
+			Where("id = ? AND deleted_at IS NOT NULL", id).
+			Updates(map[string]any{"deleted_at": nil, "updated_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return recordEvent(tx, id, model.EventRestored, before)
+		}
+
+		if before == nil {
+			return model.ErrSubscriptionNotFound
+		}
+		return model.Conflictf("subscription %s is not deleted", id)
+	})
 }
 
-func (r *SubPostgres) Delete(ctx context.Context, id uuid.UUID) error {
-	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.Subscription{})
+func (r *SubPostgres) ListPage(ctx context.Context, filter model.SubscriptionFilter, page model.PageRequest) (_ *model.SubscriptionPage, err error) {
+	ctx, span := tracer.Start(ctx, "SubPostgres.ListPage")
+	defer tracing.End(span, &err)
+
+	var subscriptions []*model.Subscription
+
+	query := applyFilter(r.db.WithContext(ctx), filter)
+
+	direction, comparison := "ASC", ">"
+	if page.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if page.Cursor != "" {
+		c, err := decodeCursor(page.Cursor, page)
+		if err != nil {
+			return nil, err
+		}
+		value, err := c.sortValue()
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", page.SortBy, comparison), value, c.ID)
+	}
+
+	result := query.
+		Order(fmt.Sprintf("%s %s, id %s", page.SortBy, direction, direction)).
+		Limit(page.Limit + 1).
+		Find(&subscriptions)
 	if result.Error != nil {
-		return result.Error
+		return nil, result.Error
 	}
-	if result.RowsAffected == 0 {
-		return errors.New("subscription not found")
+
+	res := &model.SubscriptionPage{Items: subscriptions}
+	if len(subscriptions) > page.Limit {
+		res.Items = subscriptions[:page.Limit]
+		res.NextCursor = newCursor(page, res.Items[page.Limit-1]).encode()
 	}
-	return nil
+
+	return res, nil
 }
 
-func (r *SubPostgres) ListAll(ctx context.Context) ([]*model.Subscription, error) {
+func (r *SubPostgres) ListWithFilters(ctx context.Context, filter model.SubscriptionFilter) (_ []*model.Subscription, err error) {
+	ctx, span := tracer.Start(ctx, "SubPostgres.ListWithFilters")
+	defer tracing.End(span, &err)
+
 	var subscriptions []*model.Subscription
-	result := r.db.WithContext(ctx).
+
+	result := applyFilter(r.db.WithContext(ctx), filter).
 		Order("start_date DESC").
 		Find(&subscriptions)
-
 	if result.Error != nil {
 		return nil, result.Error
 	}
+
 	return subscriptions, nil
 }
 
-func (r *SubPostgres) ListWithFilters(ctx context.Context, userID *uuid.UUID, serviceName *string, startPeriod, endPeriod *time.Time) ([]*model.Subscription, error) {
-	var subscriptions []*model.Subscription
+// Export passes every subscription matching filter to fn, reading rows one
+// by one from a cursor instead of loading them all.
+func (r *SubPostgres) Export(ctx context.Context, filter model.SubscriptionFilter, fn func(*model.Subscription) error) (err error) {
+	ctx, span := tracer.Start(ctx, "SubPostgres.Export")
+	defer tracing.End(span, &err)
 
-	query := r.db.WithContext(ctx)
+	db := r.db.WithContext(ctx)
+	rows, err := applyFilter(db.Model(&model.Subscription{}), filter).
+		Order("start_date, id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 
-	if userID != nil {
-		query = query.Where("user_id = ?", *userID)
+	for rows.Next() {
+		var sub model.Subscription
+		if err := db.ScanRows(rows, &sub); err != nil {
+			return err
+		}
+		if err := fn(&sub); err != nil {
+			return err
+		}
 	}
 
-	if serviceName != nil {
-		query = query.Where("service_name = ?", *serviceName)
+	return rows.Err()
+}
+
+func applyFilter(query *gorm.DB, filter model.SubscriptionFilter) *gorm.DB {
+	if filter.IncludeDeleted {
+		query = query.Unscoped()
 	}
 
-	if startPeriod != nil && endPeriod != nil {
-		query = query.Where("start_date <= ?", endPeriod).
-			Where("(end_date IS NOT NULL AND end_date >= ?) OR (end_date IS NULL)", startPeriod)
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
 	}
 
-	result := query.Order("start_date DESC").Find(&subscriptions)
-	if result.Error != nil {
-		return nil, result.Error
+	if filter.ServiceName != nil {
+		query = query.Where("service_name = ?", *filter.ServiceName)
 	}
 
-	return subscriptions, nil
+	if filter.ActiveTo != nil {
+		query = query.Where("start_date <= ?", *filter.ActiveTo)
+	}
+
+	if filter.ActiveFrom != nil {
+		query = query.Where("end_date IS NULL OR end_date >= ?", *filter.ActiveFrom)
+	}
+
+	if filter.StartsAfter != nil {
+		query = query.Where("start_date > ?", *filter.StartsAfter)
+	}
+
+	if filter.EndsBefore != nil {
+		query = query.Where("end_date < ?", *filter.EndsBefore)
+	}
+
+	if filter.OpenEnded != nil {
+		if *filter.OpenEnded {
+			query = query.Where("end_date IS NULL")
+		} else {
+			query = query.Where("end_date IS NOT NULL")
+		}
+	}
+
+	return query
 }
@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/internal/testdb"
+)
+
+var sortKeys = []string{model.SortByStartDate, model.SortByMonthlyCost, model.SortByServiceName, model.SortByCreatedAt}
+
+func TestListPageBreaksTiesByID(t *testing.T) {
+	sub := &model.Subscription{
+		ID:          uuid.New(),
+		ServiceName: "Netflix",
+		MonthlyCost: 100,
+		StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		CreatedAt:   time.Now(),
+	}
+
+	for _, sortBy := range sortKeys {
+		for _, desc := range []bool{false, true} {
+			rec := testdb.DryRun(t)
+			page := model.PageRequest{Limit: 10, SortBy: sortBy, Desc: desc}
+			page.Cursor = newCursor(page, sub).encode()
+
+			if _, err := NewSubPostgres(rec.DB).ListPage(context.Background(), model.SubscriptionFilter{}, page); err != nil {
+				t.Fatalf("%s desc=%v: %v", sortBy, desc, err)
+			}
+
+			statements := rec.Statements()
+			if len(statements) != 1 {
+				t.Fatalf("%s desc=%v: ran %d queries, want 1", sortBy, desc, len(statements))
+			}
+			direction, comparison := "ASC", ">"
+			if desc {
+				direction, comparison = "DESC", "<"
+			}
+			for _, want := range []string{
+				fmt.Sprintf("(%s, id) %s ($1, $2)", sortBy, comparison),
+				fmt.Sprintf("ORDER BY %s %s, id %s", sortBy, direction, direction),
+			} {
+				if !strings.Contains(statements[0], want) {
+					t.Errorf("%s desc=%v: query %q does not contain %q", sortBy, desc, statements[0], want)
+				}
+			}
+		}
+	}
+}
+
+func TestListPageRejectsInvalidCursor(t *testing.T) {
+	rec := testdb.DryRun(t)
+	repo := NewSubPostgres(rec.DB)
+	sub := &model.Subscription{ID: uuid.New(), StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	byStartDate := model.PageRequest{Limit: 10, SortBy: model.SortByStartDate, Desc: true}
+
+	for _, page := range []model.PageRequest{
+		{Limit: 10, SortBy: model.SortByStartDate, Desc: true, Cursor: "garbage"},
+		{Limit: 10, SortBy: model.SortByMonthlyCost, Desc: true, Cursor: newCursor(byStartDate, sub).encode()},
+	} {
+		if _, err := repo.ListPage(context.Background(), model.SubscriptionFilter{}, page); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %q with sort %s: err = %v, want ErrInvalidCursor", page.Cursor, page.SortBy, err)
+		}
+	}
+	if n := len(rec.Statements()); n != 0 {
+		t.Errorf("ran %d queries for invalid cursors", n)
+	}
+}
+
+func TestListPageWalksEveryRowOnce(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewSubPostgres(db)
+	ctx := context.Background()
+
+	// Pairs of rows share each sort value so that pages end inside ties.
+	userID := uuid.New()
+	createdAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	var subs []*model.Subscription
+	for i := 0; i < 9; i++ {
+		sub := &model.Subscription{
+			ServiceName:   []string{"alpha", "beta", "gamma"}[i%3],
+			MonthlyCost:   100 * (1 + i/2),
+			Price:         100 * (1 + i/2),
+			BillingPeriod: model.BillingMonthly,
+			Currency:      "RUB",
+			UserID:        userID,
+			StartDate:     time.Date(2024, time.Month(1+i/2), 1, 0, 0, 0, 0, time.UTC),
+			CreatedAt:     createdAt.Add(time.Duration(i/2) * time.Minute),
+		}
+		if err := repo.Create(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+
+	for _, sortBy := range sortKeys {
+		for _, desc := range []bool{false, true} {
+			want := sortedIDs(subs, sortBy, desc)
+
+			var got []uuid.UUID
+			page := model.PageRequest{Limit: 2, SortBy: sortBy, Desc: desc}
+			for pages := 0; ; pages++ {
+				if pages > len(subs) {
+					t.Fatalf("%s desc=%v: pagination does not end", sortBy, desc)
+				}
+				res, err := repo.ListPage(ctx, model.SubscriptionFilter{UserID: &userID}, page)
+				if err != nil {
+					t.Fatalf("%s desc=%v: %v", sortBy, desc, err)
+				}
+				for _, item := range res.Items {
+					got = append(got, item.ID)
+				}
+				if res.NextCursor == "" {
+					break
+				}
+				page.Cursor = res.NextCursor
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("%s desc=%v:\n got %v\nwant %v", sortBy, desc, got, want)
+			}
+		}
+	}
+}
+
+// sortedIDs orders subs the way ListPage does: by the sort key, then by id.
+func sortedIDs(subs []*model.Subscription, sortBy string, desc bool) []uuid.UUID {
+	sorted := append([]*model.Subscription(nil), subs...)
+	compare := func(a, b *model.Subscription) int {
+		switch sortBy {
+		case model.SortByStartDate:
+			return a.StartDate.Compare(b.StartDate)
+		case model.SortByMonthlyCost:
+			return a.MonthlyCost - b.MonthlyCost
+		case model.SortByServiceName:
+			return strings.Compare(a.ServiceName, b.ServiceName)
+		default:
+			return a.CreatedAt.Compare(b.CreatedAt)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		c := compare(sorted[i], sorted[j])
+		if c == 0 {
+			c = bytes.Compare(sorted[i].ID[:], sorted[j].ID[:])
+		}
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	ids := make([]uuid.UUID, len(sorted))
+	for i, sub := range sorted {
+		ids[i] = sub.ID
+	}
+	return ids
+}
+
+func TestCreateBatch(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewSubPostgres(db)
+	ctx := context.Background()
+
+	existing := &model.Subscription{
+		ServiceName:   "Netflix",
+		MonthlyCost:   100,
+		Price:         100,
+		BillingPeriod: model.BillingMonthly,
+		Currency:      "RUB",
+		UserID:        uuid.New(),
+		StartDate:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err := repo.Create(ctx, existing); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, partial := range []bool{false, true} {
+		batch := make([]*model.Subscription, 3)
+		for i := range batch {
+			sub := *existing
+			sub.ID, sub.UserID = uuid.New(), uuid.New()
+			batch[i] = &sub
+		}
+		batch[1].ID = existing.ID
+
+		rowErrs, err := repo.CreateBatch(ctx, batch, partial)
+		if err != nil {
+			t.Fatalf("partial=%v: %v", partial, err)
+		}
+		if len(rowErrs) != len(batch) || rowErrs[0] != nil || !errors.Is(rowErrs[1], model.ErrConflict) {
+			t.Fatalf("partial=%v: row errors %v, want a conflict on row 1 only", partial, rowErrs)
+		}
+
+		for _, i := range []int{0, 2} {
+			_, err := repo.GetByID(ctx, batch[i].ID)
+			switch {
+			case partial && err != nil:
+				t.Errorf("partial: row %d was not created: %v", i, err)
+			case !partial && !errors.Is(err, model.ErrSubscriptionNotFound):
+				t.Errorf("atomic: row %d was created", i)
+			}
+		}
+	}
+}
+
+func TestPriceEditKeepsEarlierCharges(t *testing.T) {
+	db := testdb.Open(t)
+	subs, reports := NewSubPostgres(db), NewReportPostgres(db)
+	ctx := context.Background()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	yesterday, later := today.AddDate(0, 0, -1), today.AddDate(0, 3, 0)
+
+	edits := []struct {
+		name string
+		edit func(context.Context, *model.Subscription) error
+	}{
+		{"Update", subs.Update},
+		{"Replace", subs.Replace},
+	}
+	for _, tt := range edits {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &model.Subscription{
+				ServiceName:   "Netflix",
+				MonthlyCost:   100,
+				Price:         100,
+				BillingPeriod: model.BillingMonthly,
+				Currency:      model.BaseCurrency,
+				UserID:        uuid.New(),
+				StartDate:     time.Date(today.Year()-1, today.Month(), 1, 0, 0, 0, 0, time.UTC),
+			}
+			if err := subs.Create(ctx, sub); err != nil {
+				t.Fatal(err)
+			}
+			total := func(from, to time.Time) float64 {
+				t.Helper()
+				totals, err := reports.TotalCost(ctx, model.CostQuery{UserID: &sub.UserID, PeriodStart: from, PeriodEnd: to})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return totals[model.BaseCurrency]
+			}
+			past, next := total(sub.StartDate, yesterday), total(today, later)
+
+			sub.Price, sub.MonthlyCost = 300, 300
+			if err := tt.edit(ctx, sub); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := total(sub.StartDate, yesterday); got != past {
+				t.Errorf("total before the edit = %v, want %v as before it", got, past)
+			}
+			if got := total(today, later); got != 3*next {
+				t.Errorf("total from the edit = %v, want %v at the new price", got, 3*next)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+	"gorm.io/gorm"
+)
+
+type WebhookPostgres struct {
+	db *gorm.DB
+}
+
+func NewWebhookPostgres(db *gorm.DB) *WebhookPostgres {
+	return &WebhookPostgres{db: db}
+}
+
+func (r *WebhookPostgres) CreateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	return r.db.WithContext(ctx).Create(webhook).Error
+}
+
+func (r *WebhookPostgres) GetWebhook(ctx context.Context, id uuid.UUID) (*model.Webhook, error) {
+	var webhook model.Webhook
+	result := r.db.WithContext(ctx).Where("id = ?", id).First(&webhook)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, model.ErrWebhookNotFound
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &webhook, nil
+}
+
+func (r *WebhookPostgres) ListWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	var webhooks []*model.Webhook
+	if err := r.db.WithContext(ctx).Order("created_at, id").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// UpdateWebhook replaces the URL, events and active flag of a webhook. The
+// secret is only replaced when one is given.
+func (r *WebhookPostgres) UpdateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	columns := []string{"url", "events", "active", "updated_at"}
+	if webhook.Secret != "" {
+		columns = append(columns, "secret")
+	}
+
+	webhook.UpdatedAt = time.Now()
+	result := r.db.WithContext(ctx).Model(&model.Webhook{}).
+		Where("id = ?", webhook.ID).
+		Select(columns).
+		Updates(webhook)
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// DeleteWebhook removes a webhook together with its queued deliveries.
+func (r *WebhookPostgres) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.Webhook{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// ListDeadLetters returns deliveries whose attempts are exhausted, newest
+// first.
+func (r *WebhookPostgres) ListDeadLetters(ctx context.Context, webhookID *uuid.UUID, limit int) ([]*model.WebhookDelivery, error) {
+	query := r.db.WithContext(ctx).Where("status = ?", model.DeliveryDead)
+	if webhookID != nil {
+		query = query.Where("webhook_id = ?", *webhookID)
+	}
+
+	var deliveries []*model.WebhookDelivery
+	if err := query.Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// RetryDelivery queues a dead delivery again with a fresh attempt count.
+func (r *WebhookPostgres) RetryDelivery(ctx context.Context, id int64) error {
+	result := r.db.WithContext(ctx).Model(&model.WebhookDelivery{}).
+		Where("id = ? AND status = ?", id, model.DeliveryDead).
+		Updates(map[string]any{
+			"status":          model.DeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&model.WebhookDelivery{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return model.NotFoundf("delivery %d not found", id)
+	}
+	return model.Conflictf("delivery %d is not a dead letter", id)
+}
+
+// ClaimDeliveries takes up to limit due deliveries with their webhooks and
+// postpones them by lease, so that concurrent workers skip them while they
+// are being sent.
+func (r *WebhookPostgres) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*model.WebhookDelivery, error) {
+	var ids []int64
+	err := r.db.WithContext(ctx).Raw(`
+		UPDATE webhook_deliveries SET next_attempt_at = now() + make_interval(secs => ?)
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= now()
+			ORDER BY next_attempt_at, id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id`, lease.Seconds(), model.DeliveryPending, limit).
+		Scan(&ids).Error
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	var deliveries []*model.WebhookDelivery
+	err = r.db.WithContext(ctx).
+		Preload("Webhook").
+		Where("id IN ?", ids).
+		Order("id").
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// SaveAttempt stores the outcome of sending a delivery: its status, attempt
+// count, error and time of the next attempt.
+func (r *WebhookPostgres) SaveAttempt(ctx context.Context, delivery *model.WebhookDelivery) error {
+	return r.db.WithContext(ctx).Model(&model.WebhookDelivery{}).
+		Where("id = ?", delivery.ID).
+		Updates(map[string]any{
+			"status":          delivery.Status,
+			"attempts":        delivery.Attempts,
+			"last_error":      delivery.LastError,
+			"next_attempt_at": delivery.NextAttemptAt,
+			"delivered_at":    delivery.DeliveredAt,
+		}).Error
+}
+
+// EnqueueExpired queues subscription.expired for subscriptions that ended
+// before today, once per webhook and end date. Subscriptions that ended
+// before a webhook was registered are not reported to it.
+func (r *WebhookPostgres) EnqueueExpired(ctx context.Context, today time.Time) error {
+	var subs []*model.Subscription
+	err := r.db.WithContext(ctx).
+		Where("end_date < ?", today).
+		Where(`EXISTS (
+			SELECT 1 FROM webhooks w
+			WHERE `+webhookMatches+` AND subscriptions.end_date >= w.created_at::date
+				AND NOT EXISTS (
+					SELECT 1 FROM webhook_deliveries d
+					WHERE d.webhook_id = w.id AND d.dedupe_key = 'expired:' || subscriptions.id || ':' || subscriptions.end_date
+				)
+		)`, map[string]any{"event": model.WebhookSubscriptionExpired}).
+		Find(&subs).Error
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		state, err := json.Marshal(sub.ToResponse())
+		if err != nil {
+			return err
+		}
+
+		err = r.db.WithContext(ctx).Exec(deliveryInsert+` AND CAST(@end_date AS date) >= w.created_at::date
+			ON CONFLICT (webhook_id, dedupe_key) WHERE dedupe_key IS NOT NULL DO NOTHING`,
+			map[string]any{
+				"event":           model.WebhookSubscriptionExpired,
+				"subscription_id": sub.ID,
+				"actor":           model.SystemActor,
+				"state":           string(state),
+				"dedupe_key":      "expired:" + sub.ID.String() + ":" + sub.EndDate.Format(time.DateOnly),
+				"end_date":        *sub.EndDate,
+			}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// webhookMatches selects active webhooks "w" subscribed to @event.
+const webhookMatches = `w.active AND (w.events = '[]' OR w.events @> jsonb_build_array(CAST(@event AS text)))`
+
+// deliveryInsert queues @event for every matching webhook.
+const deliveryInsert = `INSERT INTO webhook_deliveries (webhook_id, subscription_id, event, payload, dedupe_key)
+	SELECT w.id, @subscription_id, @event,
+		jsonb_build_object(
+			'event', CAST(@event AS text),
+			'occurred_at', now(),
+			'actor', CAST(@actor AS text),
+			'subscription', CAST(@state AS jsonb)
+		),
+		@dedupe_key
+	FROM webhooks w
+	WHERE ` + webhookMatches
+
+// enqueueWebhooks queues a recorded subscription event in the same
+// transaction as the change itself.
+func enqueueWebhooks(tx *gorm.DB, event *model.SubscriptionEvent) error {
+	state := event.After
+	if state == nil {
+		state = event.Before
+	}
+
+	return tx.Exec(deliveryInsert, map[string]any{
+		"event":           model.WebhookEventFor(event.Action),
+		"subscription_id": event.SubscriptionID,
+		"actor":           event.Actor,
+		"state":           state,
+		"dedupe_key":      nil,
+	}).Error
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/internal/repository"
+)
+
+const (
+	apiKeyBytes      = 32
+	apiKeyShownChars = 8
+	// lastUsedPrecision is how stale the last-used time of a key may get.
+	lastUsedPrecision = time.Minute
+)
+
+type APIKeyService struct {
+	repo repository.APIKey
+
+	// touched holds when this process last wrote the last-used time of
+	// each key, so that requests within lastUsedPrecision skip the write.
+	mu      sync.Mutex
+	touched map[uuid.UUID]time.Time
+}
+
+func NewAPIKeyService(repo repository.APIKey) *APIKeyService {
+	return &APIKeyService{repo: repo, touched: make(map[uuid.UUID]time.Time)}
+}
+
+// CreateAPIKey generates and stores a key and returns it in plain text. It
+// cannot be recovered later.
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, key *model.APIKey) (string, error) {
+	secret := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	raw := model.APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key.ID = uuid.New()
+	key.Prefix = raw[:len(model.APIKeyPrefix)+apiKeyShownChars]
+	key.Hash = hashAPIKey(raw)
+	key.LastUsedAt = nil
+	key.RevokedAt = nil
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		return "", err
+	}
+
+	return raw, nil
+}
+
+func (s *APIKeyService) ListAPIKeys(ctx context.Context) ([]*model.APIKey, error) {
+	return s.repo.ListAPIKeys(ctx)
+}
+
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	return s.repo.RevokeAPIKey(ctx, id)
+}
+
+// AuthenticateAPIKey returns the active key matching raw and records its
+// use.
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, raw string) (*model.APIKey, error) {
+	if !strings.HasPrefix(raw, model.APIKeyPrefix) {
+		return nil, model.Unauthorizedf("invalid api key")
+	}
+
+	key, err := s.repo.GetActiveAPIKey(ctx, hashAPIKey(raw))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.touch(ctx, key.ID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// touch records the use of a key unless this process recorded one less
+// than lastUsedPrecision ago.
+func (s *APIKeyService) touch(ctx context.Context, id uuid.UUID, now time.Time) error {
+	s.mu.Lock()
+	last, ok := s.touched[id]
+	s.mu.Unlock()
+	if ok && now.Sub(last) < lastUsedPrecision {
+		return nil
+	}
+
+	if err := s.repo.TouchAPIKey(ctx, id, now, lastUsedPrecision); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.touched[id] = now
+	s.mu.Unlock()
+	return nil
+}
+
+// hashAPIKey returns the stored form of a key. Keys are random, so a plain
+// SHA-256 is enough and allows looking them up by hash.
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/internal/repository"
+)
+
+// fakeAPIKeyRepo finds a key for every hash and counts the touches.
+type fakeAPIKeyRepo struct {
+	repository.APIKey
+
+	keys     map[string]*model.APIKey
+	touches  map[uuid.UUID]int
+	touchErr error
+}
+
+func (r *fakeAPIKeyRepo) GetActiveAPIKey(_ context.Context, hash string) (*model.APIKey, error) {
+	if key, ok := r.keys[hash]; ok {
+		return key, nil
+	}
+	return nil, model.Unauthorizedf("invalid api key")
+}
+
+func (r *fakeAPIKeyRepo) TouchAPIKey(_ context.Context, id uuid.UUID, _ time.Time, _ time.Duration) error {
+	if r.touchErr != nil {
+		return r.touchErr
+	}
+	r.touches[id]++
+	return nil
+}
+
+func TestAuthenticateAPIKeyCoalescesTouches(t *testing.T) {
+	first, second := model.APIKeyPrefix+"first", model.APIKeyPrefix+"second"
+	firstKey, secondKey := &model.APIKey{ID: uuid.New()}, &model.APIKey{ID: uuid.New()}
+	repo := &fakeAPIKeyRepo{
+		keys:    map[string]*model.APIKey{hashAPIKey(first): firstKey, hashAPIKey(second): secondKey},
+		touches: map[uuid.UUID]int{},
+	}
+	s := NewAPIKeyService(repo)
+	ctx := context.Background()
+
+	authenticate := func(raw string) {
+		t.Helper()
+		if _, err := s.AuthenticateAPIKey(ctx, raw); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		authenticate(first)
+	}
+	authenticate(second)
+	if repo.touches[firstKey.ID] != 1 || repo.touches[secondKey.ID] != 1 {
+		t.Fatalf("touches = %v, want one per key", repo.touches)
+	}
+
+	// Once the recorded use is older than the precision it is written again.
+	s.touched[firstKey.ID] = time.Now().Add(-lastUsedPrecision)
+	authenticate(first)
+	if repo.touches[firstKey.ID] != 2 {
+		t.Errorf("first key touched %d times, want 2", repo.touches[firstKey.ID])
+	}
+
+	// A failed write is retried by the next request.
+	s.touched[secondKey.ID] = time.Now().Add(-lastUsedPrecision)
+	repo.touchErr = errors.New("connection reset")
+	if _, err := s.AuthenticateAPIKey(ctx, second); err == nil {
+		t.Fatal("failed touch was not reported")
+	}
+	repo.touchErr = nil
+	authenticate(second)
+	if repo.touches[secondKey.ID] != 2 {
+		t.Errorf("second key touched %d times, want 2", repo.touches[secondKey.ID])
+	}
+}
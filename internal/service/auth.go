@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+)
+
+// caller returns the authenticated caller of the request.
+func caller(ctx context.Context) (*model.Principal, error) {
+	principal, ok := model.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, model.Unauthorizedf("authentication required")
+	}
+	return principal, nil
+}
+
+// scopeUserID narrows a user_id filter to the caller's own subscriptions.
+// Roles acting on all users may filter by any user or none.
+func scopeUserID(ctx context.Context, userID *uuid.UUID) (*uuid.UUID, error) {
+	principal, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if principal.AllUsers() {
+		return userID, nil
+	}
+	if principal.UserID == nil {
+		return nil, model.Forbiddenf("caller is not bound to a user")
+	}
+	if userID != nil && *userID != *principal.UserID {
+		return nil, model.Forbiddenf("cannot access subscriptions of another user")
+	}
+	return principal.UserID, nil
+}
+
+// authorizeOwner checks that the caller may create, change or assign
+// subscriptions of userID.
+func authorizeOwner(ctx context.Context, userID uuid.UUID) error {
+	principal, err := caller(ctx)
+	if err != nil {
+		return err
+	}
+	if !principal.Allows(model.ScopeSubsWrite) {
+		return model.Forbiddenf("role %s may not change subscriptions", principal.RoleName())
+	}
+	if !principal.CanAccess(userID) {
+		return model.Forbiddenf("cannot manage subscriptions of user %s", userID)
+	}
+	return nil
+}
+
+// authorizeSubscription hides subscriptions of other users from the caller
+// as if they did not exist.
+func authorizeSubscription(ctx context.Context, sub *model.Subscription) error {
+	principal, err := caller(ctx)
+	if err != nil {
+		return err
+	}
+	if !principal.CanAccess(sub.UserID) {
+		return model.ErrSubscriptionNotFound
+	}
+	return nil
+}
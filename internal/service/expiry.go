@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/internal/notifier"
+	"github.com/rezexell/em-test-task/internal/repository"
+)
+
+// ExpiryScheduler periodically reminds about subscriptions ending within a
+// number of days. Delivery state is stored, so every reminder is sent once
+// and failed ones are retried on later runs. Only one instance of the
+// scheduler is expected to run against a database. A reminder is reserved
+// before it is sent, so one whose delivery state could not be saved is not
+// sent again.
+type ExpiryScheduler struct {
+	repo     repository.Notification
+	notifier notifier.Notifier
+	days     int
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+func NewExpiryScheduler(repo repository.Notification, notifier notifier.Notifier, days int, interval time.Duration, logger *slog.Logger) *ExpiryScheduler {
+	return &ExpiryScheduler{repo: repo, notifier: notifier, days: days, interval: interval, logger: logger}
+}
+
+// Run checks for expiring subscriptions right away and then every interval
+// until ctx is canceled.
+func (s *ExpiryScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RunOnce(ctx); err != nil {
+			s.logger.Error("expiry notifications failed", slog.Any("err", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce sends the due reminders. A failed delivery is recorded and does
+// not stop the others.
+func (s *ExpiryScheduler) RunOnce(ctx context.Context) error {
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	subs, err := s.repo.ListExpiring(ctx, today, today.AddDate(0, 0, s.days), s.notifier.Channel())
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		notification := &model.ExpiryNotification{
+			SubscriptionID: sub.ID,
+			EndDate:        *sub.EndDate,
+			Channel:        s.notifier.Channel(),
+		}
+
+		reserved, err := s.repo.ReserveNotification(ctx, notification)
+		if err != nil {
+			return err
+		}
+		if !reserved {
+			continue
+		}
+
+		notification.Status = model.NotificationSent
+		if err := s.notifier.NotifyExpiry(ctx, sub); err != nil {
+			s.logger.Warn("expiry notification not delivered",
+				slog.String("id", sub.ID.String()), slog.Any("err", err))
+			message := err.Error()
+			notification.Status = model.NotificationFailed
+			notification.LastError = &message
+		} else {
+			sentAt := time.Now()
+			notification.SentAt = &sentAt
+		}
+
+		if err := s.repo.SaveNotification(ctx, notification); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
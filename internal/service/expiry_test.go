@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/internal/notifier"
+	"github.com/rezexell/em-test-task/internal/repository"
+	"github.com/rezexell/em-test-task/internal/testdb"
+)
+
+// countingNotifier counts reminders per subscription.
+type countingNotifier struct {
+	sent map[uuid.UUID]int
+	err  error
+}
+
+func (n *countingNotifier) Channel() string {
+	return notifier.ChannelLog
+}
+
+func (n *countingNotifier) NotifyExpiry(_ context.Context, sub *model.Subscription) error {
+	n.sent[sub.ID]++
+	return n.err
+}
+
+// unsavedNotifications loses the outcome of every reminder.
+type unsavedNotifications struct {
+	repository.Notification
+}
+
+func (unsavedNotifications) SaveNotification(context.Context, *model.ExpiryNotification) error {
+	return errors.New("connection reset")
+}
+
+func expiringSubscription(t *testing.T, repo repository.Subscription, endsIn int) *model.Subscription {
+	t.Helper()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	endDate := today.AddDate(0, 0, endsIn)
+	sub := &model.Subscription{
+		ServiceName:   "Netflix",
+		MonthlyCost:   100,
+		Price:         100,
+		BillingPeriod: model.BillingMonthly,
+		Currency:      "RUB",
+		UserID:        uuid.New(),
+		StartDate:     today.AddDate(0, -1, 0),
+		EndDate:       &endDate,
+	}
+	if err := repo.Create(context.Background(), sub); err != nil {
+		t.Fatal(err)
+	}
+	return sub
+}
+
+func TestExpirySchedulerSendsOnceWhenSaveFails(t *testing.T) {
+	db := testdb.Open(t)
+	repo := repository.NewRepository(db)
+	sub := expiringSubscription(t, repo.Subscription, 2)
+
+	n := &countingNotifier{sent: map[uuid.UUID]int{}}
+	scheduler := NewExpiryScheduler(unsavedNotifications{repo.Notification}, n, 7, time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	for run := 0; run < 3; run++ {
+		if err := scheduler.RunOnce(context.Background()); err == nil && run == 0 {
+			t.Error("first run did not report the failed save")
+		}
+	}
+
+	if n.sent[sub.ID] != 1 {
+		t.Errorf("reminder sent %d times, want 1", n.sent[sub.ID])
+	}
+}
+
+func TestExpirySchedulerRetriesFailedDeliveries(t *testing.T) {
+	db := testdb.Open(t)
+	repo := repository.NewRepository(db)
+	due := expiringSubscription(t, repo.Subscription, 2)
+	later := expiringSubscription(t, repo.Subscription, 30)
+
+	n := &countingNotifier{sent: map[uuid.UUID]int{}, err: errors.New("mailbox unavailable")}
+	scheduler := NewExpiryScheduler(repo.Notification, n, 7, time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	for run := 0; run < model.MaxNotificationAttempts+2; run++ {
+		if run == 2 {
+			n.err = nil
+		}
+		if err := scheduler.RunOnce(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n.sent[due.ID] != 3 {
+		t.Errorf("reminder sent %d times, want 2 failures and 1 delivery", n.sent[due.ID])
+	}
+	if n.sent[later.ID] != 0 {
+		t.Errorf("reminder for a subscription ending in 30 days sent %d times", n.sent[later.ID])
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/internal/repository"
+)
+
+type RateService struct {
+	repo repository.ExchangeRate
+}
+
+func NewRateService(repo repository.ExchangeRate) *RateService {
+	return &RateService{repo: repo}
+}
+
+// SetExchangeRates validates and stores rates. A currency listed several
+// times takes its last rate.
+func (s *RateService) SetExchangeRates(ctx context.Context, rates []*model.ExchangeRate) error {
+	if len(rates) == 0 {
+		return model.Validationf("no exchange rates given")
+	}
+
+	byCurrency := make(map[string]*model.ExchangeRate, len(rates))
+	unique := make([]*model.ExchangeRate, 0, len(rates))
+	for _, rate := range rates {
+		rate.Currency = model.NormalizeCurrency(rate.Currency)
+		if !model.IsSupportedCurrency(rate.Currency) {
+			return model.Validationf("unsupported currency %s", rate.Currency)
+		}
+		if rate.Currency == model.BaseCurrency {
+			return model.Validationf("rate of base currency %s is always 1", model.BaseCurrency)
+		}
+		if rate.Rate <= 0 {
+			return model.Validationf("rate of %s must be greater than 0", rate.Currency)
+		}
+
+		if existing, ok := byCurrency[rate.Currency]; ok {
+			existing.Rate = rate.Rate
+			continue
+		}
+		byCurrency[rate.Currency] = rate
+		unique = append(unique, rate)
+	}
+
+	return s.repo.UpsertRates(ctx, unique)
+}
+
+func (s *RateService) ListExchangeRates(ctx context.Context) ([]*model.ExchangeRate, error) {
+	return s.repo.ListRates(ctx)
+}
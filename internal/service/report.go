@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"math"
+
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/internal/repository"
+	"github.com/rezexell/em-test-task/internal/tracing"
+)
+
+type ReportService struct {
+	repo  repository.Report
+	rates repository.ExchangeRate
+}
+
+func NewReportService(repo repository.Report, rates repository.ExchangeRate) *ReportService {
+	return &ReportService{repo: repo, rates: rates}
+}
+
+func (s *ReportService) TotalSubscriptionCost(ctx context.Context, query model.CostQuery) (_ *model.CostTotal, err error) {
+	ctx, span := tracer.Start(ctx, "ReportService.TotalSubscriptionCost")
+	defer tracing.End(span, &err)
+
+	if query.PeriodStart.After(query.PeriodEnd) {
+		return nil, model.InvalidPeriodf("start period cannot be after end period")
+	}
+
+	if query.UserID, err = scopeUserID(ctx, query.UserID); err != nil {
+		return nil, err
+	}
+
+	target := model.NormalizeCurrency(query.TargetCurrency)
+	if !model.IsSupportedCurrency(target) {
+		return nil, model.Validationf("unsupported target_currency %s", query.TargetCurrency)
+	}
+
+	switch query.Proration {
+	case "":
+		query.Proration = model.ProrationNone
+	case model.ProrationNone, model.ProrationDaily:
+	default:
+		return nil, model.Validationf("unsupported proration %q", query.Proration)
+	}
+
+	subtotals, err := s.repo.TotalCost(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	rates, err := s.rates.ListRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rateByCurrency := make(map[string]float64, len(rates))
+	for _, rate := range rates {
+		rateByCurrency[rate.Currency] = rate.Rate
+	}
+
+	total, err := model.ConvertCosts(subtotals, rateByCurrency, target)
+	if err != nil {
+		return nil, err
+	}
+
+	months := (query.PeriodEnd.Year()-query.PeriodStart.Year())*12 +
+		int(query.PeriodEnd.Month()-query.PeriodStart.Month()) + 1
+	total.MonthlyEquivalent = math.Round(total.Total/float64(months)*100) / 100
+
+	return total, nil
+}
+
+func (s *ReportService) CostBreakdown(ctx context.Context, query model.CostQuery, groupBy []string) (_ []*model.CostBreakdownRow, err error) {
+	ctx, span := tracer.Start(ctx, "ReportService.CostBreakdown")
+	defer tracing.End(span, &err)
+
+	if query.PeriodStart.After(query.PeriodEnd) {
+		return nil, model.InvalidPeriodf("start period cannot be after end period")
+	}
+
+	if query.UserID, err = scopeUserID(ctx, query.UserID); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(groupBy))
+	for _, dimension := range groupBy {
+		switch dimension {
+		case model.GroupByMonth, model.GroupByService, model.GroupByUser:
+		default:
+			return nil, model.Validationf("unsupported group_by value %q", dimension)
+		}
+		if seen[dimension] {
+			return nil, model.Validationf("duplicate group_by value %q", dimension)
+		}
+		seen[dimension] = true
+	}
+
+	return s.repo.CostBreakdown(ctx, query, groupBy)
+}
@@ -5,22 +5,69 @@ import (
 	"github.com/google/uuid"
 	"github.com/rezexell/em-test-task/internal/model"
 	"github.com/rezexell/em-test-task/internal/repository"
+	"go.opentelemetry.io/otel"
 	"time"
 )
 
+var tracer = otel.Tracer("github.com/rezexell/em-test-task/internal/service")
+
 type Subscription interface {
 	CreateSubscription(ctx context.Context, sub *model.Subscription) error
+	ImportSubscriptions(ctx context.Context, rows []*model.ImportRow, mode string) (*model.ImportReport, error)
 	GetSubscription(ctx context.Context, id uuid.UUID) (*model.Subscription, error)
 	UpdateSubscription(ctx context.Context, sub *model.Subscription) error
+	PatchSubscription(ctx context.Context, id uuid.UUID, patch *model.SubscriptionPatch) (*model.Subscription, error)
 	DeleteSubscription(ctx context.Context, id uuid.UUID) error
-	ListAllSubscriptions(ctx context.Context) ([]*model.Subscription, error)
-	ListSubscriptionsWithFilters(ctx context.Context, userID *uuid.UUID, serviceName *string) ([]*model.Subscription, error)
-	TotalSubscriptionCost(ctx context.Context, userID *uuid.UUID, serviceName *string, periodStart, periodEnd time.Time) (int, error)
+	RestoreSubscription(ctx context.Context, id uuid.UUID) (*model.Subscription, error)
+	ListAllSubscriptions(ctx context.Context, includeDeleted bool, page model.PageRequest) (*model.SubscriptionPage, error)
+	ListSubscriptionsWithFilters(ctx context.Context, filter model.SubscriptionFilter, page model.PageRequest) (*model.SubscriptionPage, error)
+	ExportSubscriptions(ctx context.Context, filter model.SubscriptionFilter, fn func(*model.Subscription) error) error
+	SubscriptionHistory(ctx context.Context, id uuid.UUID) ([]*model.SubscriptionEvent, error)
+	SubscriptionStateAt(ctx context.Context, id uuid.UUID, at time.Time) (*model.SubscriptionEvent, int, error)
+	SchedulePriceChange(ctx context.Context, price *model.SubscriptionPrice) error
+	ListPriceChanges(ctx context.Context, id uuid.UUID) ([]*model.SubscriptionPrice, error)
+}
+type Report interface {
+	TotalSubscriptionCost(ctx context.Context, query model.CostQuery) (*model.CostTotal, error)
+	CostBreakdown(ctx context.Context, query model.CostQuery, groupBy []string) ([]*model.CostBreakdownRow, error)
 }
+
+type ExchangeRate interface {
+	SetExchangeRates(ctx context.Context, rates []*model.ExchangeRate) error
+	ListExchangeRates(ctx context.Context) ([]*model.ExchangeRate, error)
+}
+
+type Webhook interface {
+	CreateWebhook(ctx context.Context, webhook *model.Webhook) error
+	GetWebhook(ctx context.Context, id uuid.UUID) (*model.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]*model.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook *model.Webhook) (*model.Webhook, error)
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	ListDeadLetters(ctx context.Context, webhookID *uuid.UUID, limit int) ([]*model.WebhookDelivery, error)
+	RetryDeadLetter(ctx context.Context, id int64) error
+}
+
+type APIKey interface {
+	CreateAPIKey(ctx context.Context, key *model.APIKey) (string, error)
+	ListAPIKeys(ctx context.Context) ([]*model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id uuid.UUID) error
+	AuthenticateAPIKey(ctx context.Context, raw string) (*model.APIKey, error)
+}
+
 type Service struct {
 	Subscription
+	Report
+	ExchangeRate
+	Webhook
+	APIKey
 }
 
 func NewService(repo *repository.Repository) *Service {
-	return &Service{Subscription: NewSubService(repo.Subscription)}
+	return &Service{
+		Subscription: NewSubService(repo.Subscription, repo.History, repo.Price),
+		Report:       NewReportService(repo.Report, repo.ExchangeRate),
+		ExchangeRate: NewRateService(repo.ExchangeRate),
+		Webhook:      NewWebhookService(repo.Webhook),
+		APIKey:       NewAPIKeyService(repo.APIKey),
+	}
 }
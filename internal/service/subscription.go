@@ -2,119 +2,359 @@ package service
 
 import (
 	"context"
-	"errors"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/rezexell/em-test-task/internal/model"
 	"github.com/rezexell/em-test-task/internal/repository"
-	"time"
+	"github.com/rezexell/em-test-task/internal/tracing"
 )
 
 type SubService struct {
-	repo repository.Subscription
+	repo    repository.Subscription
+	history repository.History
+	prices  repository.Price
 }
 
-func NewSubService(repo repository.Subscription) *SubService {
-	return &SubService{repo: repo}
+func NewSubService(repo repository.Subscription, history repository.History, prices repository.Price) *SubService {
+	return &SubService{repo: repo, history: history, prices: prices}
 }
 
-func (s *SubService) CreateSubscription(ctx context.Context, sub *model.Subscription) error {
+func (s *SubService) CreateSubscription(ctx context.Context, sub *model.Subscription) (err error) {
+	ctx, span := tracer.Start(ctx, "SubService.CreateSubscription")
+	defer tracing.End(span, &err)
+
+	if err := authorizeOwner(ctx, sub.UserID); err != nil {
+		return err
+	}
+
 	return s.repo.Create(ctx, sub)
 }
 
-func (s *SubService) GetSubscription(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
+// ImportSubscriptions creates the valid rows. In atomic mode nothing is
+// created when any row is invalid or fails to insert.
+func (s *SubService) ImportSubscriptions(ctx context.Context, rows []*model.ImportRow, mode string) (_ *model.ImportReport, err error) {
+	ctx, span := tracer.Start(ctx, "SubService.ImportSubscriptions")
+	defer tracing.End(span, &err)
+
+	switch mode {
+	case "":
+		mode = model.ImportAtomic
+	case model.ImportAtomic, model.ImportPartial:
+	default:
+		return nil, model.Validationf("unsupported import mode %q", mode)
+	}
+	if len(rows) == 0 {
+		return nil, model.Validationf("import contains no rows")
+	}
+	if len(rows) > model.MaxImportRows {
+		return nil, model.Validationf("import cannot contain more than %d rows", model.MaxImportRows)
+	}
+
+	if _, err := caller(ctx); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if row.Err == nil {
+			row.Err = authorizeOwner(ctx, row.Subscription.UserID)
+		}
+	}
+
+	report := &model.ImportReport{Mode: mode, Rows: make([]*model.ImportRowResult, len(rows))}
+	var (
+		valid   []*model.Subscription
+		indexes []int
+	)
+	for i, row := range rows {
+		report.Rows[i] = &model.ImportRowResult{Line: row.Line, Status: model.ImportSkipped}
+		if row.Err != nil {
+			report.Rows[i].Status = model.ImportFailed
+			report.Rows[i].Error = row.Err.Error()
+			report.Failed++
+			continue
+		}
+		valid = append(valid, row.Subscription)
+		indexes = append(indexes, i)
+	}
+
+	if report.Failed > 0 && mode == model.ImportAtomic {
+		report.RollBack()
+		return report, nil
+	}
+	if len(valid) == 0 {
+		return report, nil
+	}
+
+	rowErrs, err := s.repo.CreateBatch(ctx, valid, mode == model.ImportPartial)
+	if err != nil {
+		return nil, err
+	}
+
+	failed := false
+	for j, rowErr := range rowErrs {
+		result := report.Rows[indexes[j]]
+		if rowErr != nil {
+			result.Status = model.ImportFailed
+			result.Error = rowErr.Error()
+			report.Failed++
+			failed = true
+		}
+	}
+	if failed && mode == model.ImportAtomic {
+		report.RollBack()
+		return report, nil
+	}
+
+	for j, rowErr := range rowErrs {
+		if rowErr == nil {
+			result := report.Rows[indexes[j]]
+			result.Status = model.ImportCreated
+			result.ID = &valid[j].ID
+			report.Created++
+		}
+	}
+
+	return report, nil
+}
+
+func (s *SubService) GetSubscription(ctx context.Context, id uuid.UUID) (_ *model.Subscription, err error) {
+	ctx, span := tracer.Start(ctx, "SubService.GetSubscription")
+	defer tracing.End(span, &err)
+
 	if id == uuid.Nil {
-		return nil, errors.New("invalid subscription ID")
+		return nil, model.Validationf("invalid subscription ID")
 	}
 
-	return s.repo.GetByID(ctx, id)
+	sub, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	return sub, nil
 }
 
-func (s *SubService) UpdateSubscription(ctx context.Context, sub *model.Subscription) error {
+func (s *SubService) UpdateSubscription(ctx context.Context, sub *model.Subscription) (err error) {
+	ctx, span := tracer.Start(ctx, "SubService.UpdateSubscription")
+	defer tracing.End(span, &err)
+
+	if _, err := s.GetSubscription(ctx, sub.ID); err != nil {
+		return err
+	}
+	if err := authorizeOwner(ctx, sub.UserID); err != nil {
+		return err
+	}
+
 	return s.repo.Update(ctx, sub)
 }
 
-func (s *SubService) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+func (s *SubService) PatchSubscription(ctx context.Context, id uuid.UUID, patch *model.SubscriptionPatch) (_ *model.Subscription, err error) {
+	ctx, span := tracer.Start(ctx, "SubService.PatchSubscription")
+	defer tracing.End(span, &err)
+
+	sub, err := s.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := patch.Apply(sub); err != nil {
+		return nil, err
+	}
+	if err := authorizeOwner(ctx, sub.UserID); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Replace(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+func (s *SubService) DeleteSubscription(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := tracer.Start(ctx, "SubService.DeleteSubscription")
+	defer tracing.End(span, &err)
+
 	if id == uuid.Nil {
-		return errors.New("invalid subscription ID")
+		return model.Validationf("invalid subscription ID")
+	}
+
+	sub, err := s.GetSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := authorizeOwner(ctx, sub.UserID); err != nil {
+		return err
 	}
 
 	return s.repo.Delete(ctx, id)
 }
 
-func (s *SubService) ListAllSubscriptions(ctx context.Context) ([]*model.Subscription, error) {
-	return s.repo.ListAll(ctx)
+func (s *SubService) RestoreSubscription(ctx context.Context, id uuid.UUID) (_ *model.Subscription, err error) {
+	ctx, span := tracer.Start(ctx, "SubService.RestoreSubscription")
+	defer tracing.End(span, &err)
+
+	if id == uuid.Nil {
+		return nil, model.Validationf("invalid subscription ID")
+	}
+
+	sub, err := s.getWithDeleted(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeOwner(ctx, sub.UserID); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Restore(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(ctx, id)
 }
 
-func (s *SubService) ListSubscriptionsWithFilters(ctx context.Context, userID *uuid.UUID, serviceName *string) ([]*model.Subscription, error) {
-	return s.repo.ListWithFilters(ctx, userID, serviceName, nil, nil)
-	//TODO: Сделать фильтр по дате
+func (s *SubService) ListAllSubscriptions(ctx context.Context, includeDeleted bool, page model.PageRequest) (_ *model.SubscriptionPage, err error) {
+	ctx, span := tracer.Start(ctx, "SubService.ListAllSubscriptions")
+	defer tracing.End(span, &err)
+
+	userID, err := scopeUserID(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListPage(ctx, model.SubscriptionFilter{UserID: userID, IncludeDeleted: includeDeleted}, page)
 }
 
-func (s *SubService) TotalSubscriptionCost(ctx context.Context, userID *uuid.UUID, serviceName *string, periodStart, periodEnd time.Time) (int, error) {
-	if periodStart.After(periodEnd) {
-		return 0, errors.New("start period cannot be after end period")
+func (s *SubService) ListSubscriptionsWithFilters(ctx context.Context, filter model.SubscriptionFilter, page model.PageRequest) (_ *model.SubscriptionPage, err error) {
+	ctx, span := tracer.Start(ctx, "SubService.ListSubscriptionsWithFilters")
+	defer tracing.End(span, &err)
+
+	if filter.ActiveFrom != nil && filter.ActiveTo != nil && filter.ActiveFrom.After(*filter.ActiveTo) {
+		return nil, model.InvalidPeriodf("active_from cannot be after active_to")
 	}
 
-	subscriptions, err := s.repo.ListWithFilters(
-		ctx,
-		userID,
-		serviceName,
-		&periodStart,
-		&periodEnd,
-	)
+	if filter.UserID, err = scopeUserID(ctx, filter.UserID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListPage(ctx, filter, page)
+}
+
+func (s *SubService) ExportSubscriptions(ctx context.Context, filter model.SubscriptionFilter, fn func(*model.Subscription) error) (err error) {
+	ctx, span := tracer.Start(ctx, "SubService.ExportSubscriptions")
+	defer tracing.End(span, &err)
+
+	if filter.ActiveFrom != nil && filter.ActiveTo != nil && filter.ActiveFrom.After(*filter.ActiveTo) {
+		return model.InvalidPeriodf("active_from cannot be after active_to")
+	}
 
+	if filter.UserID, err = scopeUserID(ctx, filter.UserID); err != nil {
+		return err
+	}
+
+	return s.repo.Export(ctx, filter, fn)
+}
+
+func (s *SubService) SchedulePriceChange(ctx context.Context, price *model.SubscriptionPrice) (err error) {
+	ctx, span := tracer.Start(ctx, "SubService.SchedulePriceChange")
+	defer tracing.End(span, &err)
+
+	sub, err := s.GetSubscription(ctx, price.SubscriptionID)
 	if err != nil {
-		return 0, err
+		return err
+	}
+	if err := authorizeOwner(ctx, sub.UserID); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	currentMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if !price.EffectiveFrom.After(currentMonth) {
+		return model.InvalidPeriodf("effective_from must be a future month")
+	}
+	if !price.EffectiveFrom.After(sub.StartDate) {
+		return model.InvalidPeriodf("effective_from must be after start_date")
+	}
+	if sub.EndDate != nil && price.EffectiveFrom.After(*sub.EndDate) {
+		return model.InvalidPeriodf("effective_from cannot be after end_date")
 	}
 
-	total := 0
-	for _, sub := range subscriptions {
-		activeMonths := calculateActiveMonths(
-			sub.StartDate,
-			sub.EndDate,
-			periodStart,
-			periodEnd,
-		)
+	return s.prices.CreatePrice(ctx, price)
+}
 
-		total += sub.MonthlyCost * activeMonths
+func (s *SubService) ListPriceChanges(ctx context.Context, id uuid.UUID) (_ []*model.SubscriptionPrice, err error) {
+	ctx, span := tracer.Start(ctx, "SubService.ListPriceChanges")
+	defer tracing.End(span, &err)
+
+	if _, err := s.GetSubscription(ctx, id); err != nil {
+		return nil, err
 	}
 
-	return total, nil
+	return s.prices.ListPrices(ctx, id)
 }
 
-func calculateActiveMonths(subStart time.Time, subEnd *time.Time, periodStart, periodEnd time.Time) int {
-	activityStart := subStart
-	if subStart.Before(periodStart) {
-		activityStart = periodStart
+func (s *SubService) SubscriptionHistory(ctx context.Context, id uuid.UUID) (_ []*model.SubscriptionEvent, err error) {
+	ctx, span := tracer.Start(ctx, "SubService.SubscriptionHistory")
+	defer tracing.End(span, &err)
+
+	if id == uuid.Nil {
+		return nil, model.Validationf("invalid subscription ID")
 	}
 
-	var activityEnd time.Time
-	if subEnd == nil {
-		activityEnd = periodEnd
-	} else {
-		activityEnd = *subEnd
-		if activityEnd.After(periodEnd) {
-			activityEnd = periodEnd
-		}
+	if _, err := s.getWithDeleted(ctx, id); err != nil {
+		return nil, err
 	}
 
-	if activityStart.After(activityEnd) {
-		return 0
+	events, err := s.history.ListEvents(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, model.ErrSubscriptionNotFound
 	}
 
-	return countFullMonthsBetween(activityStart, activityEnd)
+	return events, nil
 }
 
-func countFullMonthsBetween(start, end time.Time) int {
-	start = time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
-	end = time.Date(end.Year(), end.Month(), 1, 0, 0, 0, 0, time.UTC)
+// getWithDeleted returns a subscription the caller may access, including a
+// soft-deleted one.
+func (s *SubService) getWithDeleted(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
+	sub, err := s.repo.GetByIDWithDeleted(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
 
-	months := 0
-	for current := start; !current.After(end); current = current.AddDate(0, 1, 0) {
-		months++
+// SubscriptionStateAt returns the latest event recorded at or before at
+// together with its version number. The event's After snapshot is the
+// state of the subscription at that moment.
+func (s *SubService) SubscriptionStateAt(ctx context.Context, id uuid.UUID, at time.Time) (_ *model.SubscriptionEvent, _ int, err error) {
+	ctx, span := tracer.Start(ctx, "SubService.SubscriptionStateAt")
+	defer tracing.End(span, &err)
+
+	events, err := s.SubscriptionHistory(ctx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	version := 0
+	for i, event := range events {
+		if event.OccurredAt.After(at) {
+			break
+		}
+		version = i + 1
+	}
+	if version == 0 {
+		return nil, 0, model.NotFoundf("no recorded state of subscription %s as of %s", id, at.Format(time.RFC3339))
 	}
 
-	return months
+	return events[version-1], version, nil
 }
 
 //func reqToModel(req *model.SubReq) (*model.Subscription, error) {
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/internal/repository"
+)
+
+// fakeSubRepo records the calls of the service. Methods a test does not
+// set panic through the nil embedded interface.
+type fakeSubRepo struct {
+	repository.Subscription
+
+	listPage    func(filter model.SubscriptionFilter, page model.PageRequest) (*model.SubscriptionPage, error)
+	createBatch func(subs []*model.Subscription, partial bool) ([]error, error)
+}
+
+func (r *fakeSubRepo) ListPage(_ context.Context, filter model.SubscriptionFilter, page model.PageRequest) (*model.SubscriptionPage, error) {
+	return r.listPage(filter, page)
+}
+
+func (r *fakeSubRepo) CreateBatch(_ context.Context, subs []*model.Subscription, partial bool) ([]error, error) {
+	return r.createBatch(subs, partial)
+}
+
+func asPrincipal(principal *model.Principal) context.Context {
+	return model.WithPrincipal(context.Background(), principal)
+}
+
+func TestListAllSubscriptionsUsesListPage(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		name      string
+		principal *model.Principal
+		want      *uuid.UUID
+	}{
+		{"admin sees every user", &model.Principal{Subject: "admin", Roles: []string{model.RoleAdmin}}, nil},
+		{"analyst sees every user", &model.Principal{Subject: "analyst", Roles: []string{model.RoleAnalyst}}, nil},
+		{"user sees own subscriptions", &model.Principal{Subject: "user", UserID: &userID}, &userID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := model.PageRequest{Limit: 5, Cursor: "next", SortBy: model.SortByCreatedAt, Desc: true}
+
+			var calls int
+			repo := &fakeSubRepo{listPage: func(filter model.SubscriptionFilter, got model.PageRequest) (*model.SubscriptionPage, error) {
+				calls++
+				if (filter.UserID == nil) != (tt.want == nil) || (tt.want != nil && *filter.UserID != *tt.want) {
+					t.Errorf("filter.UserID = %v, want %v", filter.UserID, tt.want)
+				}
+				if !filter.IncludeDeleted {
+					t.Error("IncludeDeleted was not passed through")
+				}
+				if got != page {
+					t.Errorf("page = %+v, want %+v", got, page)
+				}
+				return &model.SubscriptionPage{}, nil
+			}}
+
+			if _, err := NewSubService(repo, nil, nil).ListAllSubscriptions(asPrincipal(tt.principal), true, page); err != nil {
+				t.Fatal(err)
+			}
+			if calls != 1 {
+				t.Errorf("ListPage called %d times, want 1", calls)
+			}
+		})
+	}
+}
+
+func TestImportSubscriptions(t *testing.T) {
+	admin := asPrincipal(&model.Principal{Subject: "admin", Roles: []string{model.RoleAdmin}})
+	invalid := model.Validationf("invalid price")
+	duplicate := model.Conflictf("subscription already exists")
+
+	// rows are lines 2, 3, 5 and 8 of the file; line 5 does not parse.
+	rows := func() []*model.ImportRow {
+		var rows []*model.ImportRow
+		for _, line := range []int{2, 3, 5, 8} {
+			row := &model.ImportRow{Line: line, Subscription: &model.Subscription{ID: uuid.New(), UserID: uuid.New()}}
+			if line == 5 {
+				row.Subscription, row.Err = nil, invalid
+			}
+			rows = append(rows, row)
+		}
+		return rows
+	}
+
+	tests := []struct {
+		name    string
+		mode    string
+		rows    []*model.ImportRow
+		rowErrs []error
+		batches int
+		want    []string
+		created int
+		failed  int
+	}{
+		{
+			name:    "atomic import of valid rows",
+			mode:    model.ImportAtomic,
+			rows:    rows()[:2],
+			rowErrs: []error{nil, nil},
+			batches: 1,
+			want:    []string{"2 created", "3 created"},
+			created: 2,
+		},
+		{
+			name: "atomic import with an invalid row",
+			mode: "",
+			rows: rows(),
+			want: []string{
+				"2 skipped: not imported: batch rolled back",
+				"3 skipped: not imported: batch rolled back",
+				"5 failed: invalid price",
+				"8 skipped: not imported: batch rolled back",
+			},
+			failed: 1,
+		},
+		{
+			name:    "atomic import with a row failing to insert",
+			mode:    model.ImportAtomic,
+			rows:    rows()[:2],
+			rowErrs: []error{nil, duplicate},
+			batches: 1,
+			want: []string{
+				"2 skipped: not imported: batch rolled back",
+				"3 failed: subscription already exists",
+			},
+			failed: 1,
+		},
+		{
+			name:    "partial import",
+			mode:    model.ImportPartial,
+			rows:    rows(),
+			rowErrs: []error{nil, duplicate, nil},
+			batches: 1,
+			want: []string{
+				"2 created",
+				"3 failed: subscription already exists",
+				"5 failed: invalid price",
+				"8 created",
+			},
+			created: 2,
+			failed:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var batches int
+			repo := &fakeSubRepo{createBatch: func(subs []*model.Subscription, partial bool) ([]error, error) {
+				batches++
+				if partial != (tt.mode == model.ImportPartial) {
+					t.Errorf("partial = %v in mode %q", partial, tt.mode)
+				}
+				if len(subs) != len(tt.rowErrs) {
+					t.Fatalf("got %d subscriptions, want %d", len(subs), len(tt.rowErrs))
+				}
+				return tt.rowErrs, nil
+			}}
+
+			report, err := NewSubService(repo, nil, nil).ImportSubscriptions(admin, tt.rows, tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if batches != tt.batches {
+				t.Errorf("CreateBatch called %d times, want %d", batches, tt.batches)
+			}
+			if report.Created != tt.created || report.Failed != tt.failed {
+				t.Errorf("created %d, failed %d, want %d and %d", report.Created, report.Failed, tt.created, tt.failed)
+			}
+
+			got := make([]string, len(report.Rows))
+			for i, row := range report.Rows {
+				got[i] = fmt.Sprintf("%d %s", row.Line, row.Status)
+				if row.Error != "" {
+					got[i] += ": " + row.Error
+				}
+				if (row.ID != nil) != (row.Status == model.ImportCreated) {
+					t.Errorf("line %d: status %s with ID %v", row.Line, row.Status, row.ID)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("rows:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	spansOnce sync.Once
+	spans     *tracetest.SpanRecorder
+)
+
+// recordSpans installs a global tracer provider recording every span. The
+// package tracer delegates only to the first provider installed, so the
+// recorder is shared and tests tell their spans apart by trace ID.
+func recordSpans() *tracetest.SpanRecorder {
+	spansOnce.Do(func() {
+		spans = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	})
+	return spans
+}
+
+// tracedSpans returns the ended spans of the trace by name.
+func tracedSpans(recorder *tracetest.SpanRecorder, traceID trace.TraceID) map[string]sdktrace.ReadOnlySpan {
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() == traceID {
+			byName[span.Name()] = span
+		}
+	}
+	return byName
+}
+
+// getByIDRepo fails GetByID with err.
+type getByIDRepo struct {
+	fakeSubRepo
+	err error
+}
+
+func (r *getByIDRepo) GetByID(context.Context, uuid.UUID) (*model.Subscription, error) {
+	return nil, r.err
+}
+
+func TestSubServiceSpansRecordErrors(t *testing.T) {
+	recorder := recordSpans()
+	dbErr := errors.New("connection reset")
+	svc := NewSubService(&getByIDRepo{err: dbErr}, nil, nil)
+
+	ctx, root := otel.Tracer("test").Start(asPrincipal(&model.Principal{Subject: "admin", Roles: []string{model.RoleAdmin}}), "test")
+	_, err := svc.PatchSubscription(ctx, uuid.New(), &model.SubscriptionPatch{})
+	root.End()
+	if !errors.Is(err, dbErr) {
+		t.Fatalf("err = %v, want %v", err, dbErr)
+	}
+
+	byName := tracedSpans(recorder, root.SpanContext().TraceID())
+	patch, get := byName["SubService.PatchSubscription"], byName["SubService.GetSubscription"]
+	if patch == nil || get == nil {
+		t.Fatalf("spans %v, want SubService.PatchSubscription and SubService.GetSubscription", byName)
+	}
+	if get.Parent().SpanID() != patch.SpanContext().SpanID() {
+		t.Error("GetSubscription span is not a child of the PatchSubscription span")
+	}
+	for _, span := range []sdktrace.ReadOnlySpan{patch, get} {
+		if span.Status().Code != codes.Error || span.Status().Description != dbErr.Error() {
+			t.Errorf("%s: status = %+v, want error %q", span.Name(), span.Status(), dbErr)
+		}
+		if len(span.Events()) != 1 || span.Events()[0].Name != "exception" {
+			t.Errorf("%s: events = %v, want the recorded error", span.Name(), span.Events())
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/internal/repository"
+)
+
+type WebhookService struct {
+	repo repository.Webhook
+}
+
+func NewWebhookService(repo repository.Webhook) *WebhookService {
+	return &WebhookService{repo: repo}
+}
+
+// CreateWebhook registers a webhook, generating its secret unless one is
+// given.
+func (s *WebhookService) CreateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	normalizeEvents(webhook)
+	if webhook.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		webhook.Secret = hex.EncodeToString(secret)
+	}
+	webhook.ID = uuid.New()
+
+	return s.repo.CreateWebhook(ctx, webhook)
+}
+
+// normalizeEvents stores a missing events list as an empty one, which
+// subscribes to every event. The events column cannot be NULL.
+func normalizeEvents(webhook *model.Webhook) {
+	if webhook.Events == nil {
+		webhook.Events = []string{}
+	}
+}
+
+func (s *WebhookService) GetWebhook(ctx context.Context, id uuid.UUID) (*model.Webhook, error) {
+	return s.repo.GetWebhook(ctx, id)
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	return s.repo.ListWebhooks(ctx)
+}
+
+func (s *WebhookService) UpdateWebhook(ctx context.Context, webhook *model.Webhook) (*model.Webhook, error) {
+	normalizeEvents(webhook)
+	if webhook.Active == nil {
+		active := true
+		webhook.Active = &active
+	}
+	if err := s.repo.UpdateWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetWebhook(ctx, webhook.ID)
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	return s.repo.DeleteWebhook(ctx, id)
+}
+
+func (s *WebhookService) ListDeadLetters(ctx context.Context, webhookID *uuid.UUID, limit int) ([]*model.WebhookDelivery, error) {
+	if limit < 1 || limit > model.MaxPageLimit {
+		return nil, model.Validationf("limit must be between 1 and %d", model.MaxPageLimit)
+	}
+
+	return s.repo.ListDeadLetters(ctx, webhookID, limit)
+}
+
+func (s *WebhookService) RetryDeadLetter(ctx context.Context, id int64) error {
+	return s.repo.RetryDelivery(ctx, id)
+}
+
+// Headers of a webhook request. The signature is the hex HMAC-SHA256 of the
+// body keyed with the webhook secret, prefixed with "sha256=".
+const (
+	SignatureHeader = "X-Signature-256"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+const (
+	deliveryBatch   = 50
+	deliveryLease   = time.Minute
+	deliveryTimeout = 10 * time.Second
+	retryBaseDelay  = 10 * time.Second
+	retryMaxDelay   = time.Hour
+	expirySweep     = time.Minute
+)
+
+// WebhookDispatcher sends queued webhook deliveries. Failed ones are retried
+// with exponential backoff until MaxDeliveryAttempts, then left as dead
+// letters. It also queues subscription.expired events.
+type WebhookDispatcher struct {
+	repo     repository.Webhook
+	client   *http.Client
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+func NewWebhookDispatcher(repo repository.Webhook, interval time.Duration, logger *slog.Logger) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		repo:     repo,
+		client:   &http.Client{Timeout: deliveryTimeout},
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Run polls for due deliveries every interval until ctx is canceled.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	var lastSweep time.Time
+	for {
+		if time.Since(lastSweep) >= expirySweep {
+			now := time.Now().UTC()
+			today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+			if err := d.repo.EnqueueExpired(ctx, today); err != nil {
+				d.logger.Error("queueing expired subscriptions failed", slog.Any("err", err))
+			}
+			lastSweep = time.Now()
+		}
+
+		if err := d.DispatchOnce(ctx); err != nil {
+			d.logger.Error("webhook dispatch failed", slog.Any("err", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce sends one batch of due deliveries.
+func (d *WebhookDispatcher) DispatchOnce(ctx context.Context) error {
+	deliveries, err := d.repo.ClaimDeliveries(ctx, deliveryBatch, deliveryLease)
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		delivery.Attempts++
+		if err := d.send(ctx, delivery); err != nil {
+			message := err.Error()
+			delivery.LastError = &message
+			delivery.NextAttemptAt = time.Now().Add(retryDelay(delivery.Attempts))
+			if delivery.Attempts >= model.MaxDeliveryAttempts {
+				delivery.Status = model.DeliveryDead
+			}
+			d.logger.Warn("webhook not delivered",
+				slog.Int64("delivery", delivery.ID), slog.Int("attempts", delivery.Attempts), slog.Any("err", err))
+		} else {
+			now := time.Now()
+			delivery.Status = model.DeliveryDelivered
+			delivery.DeliveredAt = &now
+			delivery.LastError = nil
+		}
+
+		if err := d.repo.SaveAttempt(ctx, delivery); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *WebhookDispatcher) send(ctx context.Context, delivery *model.WebhookDelivery) error {
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(delivery.Webhook.Secret, body))
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the signature header value of body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryDelay doubles the delay after every failed attempt, up to
+// retryMaxDelay.
+func retryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, retryMaxDelay)
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rezexell/em-test-task/internal/model"
+	"github.com/rezexell/em-test-task/internal/repository"
+	"github.com/rezexell/em-test-task/internal/testdb"
+)
+
+func TestWebhookWithoutEventsStoresEmptyList(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		want   string
+	}{
+		{"missing events", nil, `'[]'`},
+		{"empty events", []string{}, `'[]'`},
+		{"listed events", []string{"subscription.created"}, `'["subscription.created"]'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := testdb.DryRun(t)
+			s := NewWebhookService(repository.NewWebhookPostgres(rec.DB))
+
+			if err := s.CreateWebhook(context.Background(), &model.Webhook{URL: "https://example.com/hook", Events: tt.events}); err != nil {
+				t.Fatal(err)
+			}
+			// A dry run updates no rows, so the update reports not found.
+			_, _ = s.UpdateWebhook(context.Background(), &model.Webhook{ID: uuid.New(), URL: "https://example.com/hook", Events: tt.events})
+
+			statements := rec.Explained()
+			if len(statements) < 2 {
+				t.Fatalf("ran %d statements, want an insert and an update", len(statements))
+			}
+			for _, statement := range statements[:2] {
+				if !strings.Contains(statement, tt.want) {
+					t.Errorf("statement %q does not store events as %s", statement, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package tracing
+
+import (
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// End ends span, recording *err on it first when it is not nil. Defer it
+// with the address of the named error result:
+//
+//	ctx, span := tracer.Start(ctx, "SubPostgres.Create")
+//	defer tracing.End(span, &err)
+func End(span trace.Span, err *error) {
+	if *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestEnd(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus codes.Code
+		wantEvents int
+	}{
+		{"success", nil, codes.Unset, 0},
+		{"failure", errors.New("connection reset"), codes.Error, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+			func() (err error) {
+				_, span := tracer.Start(context.Background(), "op")
+				defer End(span, &err)
+				return tt.err
+			}()
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("%d spans ended, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.Status().Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", span.Status().Code, tt.wantStatus)
+			}
+			if tt.err != nil && span.Status().Description != tt.err.Error() {
+				t.Errorf("status description = %q, want %q", span.Status().Description, tt.err)
+			}
+			if len(span.Events()) != tt.wantEvents {
+				t.Errorf("%d events, want %d", len(span.Events()), tt.wantEvents)
+			}
+		})
+	}
+}
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_subscriptions_created_at_id;
+DROP INDEX IF EXISTS idx_subscriptions_start_date_id;
+
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS created_at;
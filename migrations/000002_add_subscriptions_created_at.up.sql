@@ -0,0 +1,7 @@
+ALTER TABLE subscriptions
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+COMMENT ON COLUMN subscriptions.created_at IS 'Дата создания записи';
+
+CREATE INDEX idx_subscriptions_start_date_id ON subscriptions(start_date, id);
+CREATE INDEX idx_subscriptions_created_at_id ON subscriptions(created_at, id);
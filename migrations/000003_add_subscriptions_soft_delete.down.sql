@@ -0,0 +1,15 @@
+-- Удаленные подписки после отката стали бы активными, поэтому откат
+-- запрещен, пока они есть. Их нужно восстановить или удалить вручную.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM subscriptions WHERE deleted_at IS NOT NULL) THEN
+        RAISE EXCEPTION 'cannot drop subscriptions.deleted_at: soft-deleted subscriptions exist';
+    END IF;
+END
+$$;
+
+DROP INDEX IF EXISTS idx_subscriptions_deleted_at;
+
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS updated_at;
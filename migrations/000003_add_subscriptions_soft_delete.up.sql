@@ -0,0 +1,8 @@
+ALTER TABLE subscriptions
+    ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN deleted_at TIMESTAMPTZ NULL;
+
+COMMENT ON COLUMN subscriptions.updated_at IS 'Дата последнего изменения записи';
+COMMENT ON COLUMN subscriptions.deleted_at IS 'Дата удаления записи (NULL для активных)';
+
+CREATE INDEX idx_subscriptions_deleted_at ON subscriptions(deleted_at);
@@ -0,0 +1,17 @@
+CREATE TABLE subscription_events (
+                                     id BIGSERIAL PRIMARY KEY,
+                                     subscription_id UUID NOT NULL,
+                                     action TEXT NOT NULL,
+                                     before JSONB NULL,
+                                     after JSONB NULL,
+                                     actor TEXT NOT NULL,
+                                     occurred_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+COMMENT ON TABLE subscription_events IS 'История изменений подписок';
+COMMENT ON COLUMN subscription_events.action IS 'Тип изменения: created, updated, deleted, restored';
+COMMENT ON COLUMN subscription_events.before IS 'Состояние подписки до изменения';
+COMMENT ON COLUMN subscription_events.after IS 'Состояние подписки после изменения';
+COMMENT ON COLUMN subscription_events.actor IS 'Автор изменения';
+
+CREATE INDEX idx_subscription_events_subscription_id ON subscription_events(subscription_id, occurred_at);
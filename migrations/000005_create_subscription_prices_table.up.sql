@@ -0,0 +1,12 @@
+CREATE TABLE subscription_prices (
+                                     id BIGSERIAL PRIMARY KEY,
+                                     subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+                                     effective_from DATE NOT NULL,
+                                     monthly_cost INTEGER NOT NULL CHECK (monthly_cost > 0),
+                                     created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                                     UNIQUE (subscription_id, effective_from)
+);
+
+COMMENT ON TABLE subscription_prices IS 'Изменения месячной стоимости подписок';
+COMMENT ON COLUMN subscription_prices.effective_from IS 'Первый месяц действия цены';
+COMMENT ON COLUMN subscription_prices.monthly_cost IS 'Месячная стоимость в рублях начиная с effective_from';
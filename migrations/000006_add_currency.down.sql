@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS exchange_rates;
+
+COMMENT ON COLUMN subscription_prices.monthly_cost IS 'Месячная стоимость в рублях начиная с effective_from';
+COMMENT ON COLUMN subscriptions.monthly_cost IS 'Месячная стоимость в рублях';
+
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS currency;
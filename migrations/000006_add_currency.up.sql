@@ -0,0 +1,15 @@
+ALTER TABLE subscriptions
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'RUB';
+
+COMMENT ON COLUMN subscriptions.currency IS 'Валюта стоимости (ISO 4217)';
+COMMENT ON COLUMN subscriptions.monthly_cost IS 'Месячная стоимость в валюте currency';
+COMMENT ON COLUMN subscription_prices.monthly_cost IS 'Месячная стоимость в валюте подписки начиная с effective_from';
+
+CREATE TABLE exchange_rates (
+                                currency CHAR(3) PRIMARY KEY,
+                                rate NUMERIC(18, 8) NOT NULL CHECK (rate > 0),
+                                updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+COMMENT ON TABLE exchange_rates IS 'Курсы валют к рублю';
+COMMENT ON COLUMN exchange_rates.rate IS 'Стоимость единицы валюты в рублях';
@@ -0,0 +1,28 @@
+-- До этой миграции подписки списывались только помесячно. Подписку с другим
+-- периодом нельзя откатить без потери ее цены (месячный эквивалент дешевой
+-- годовой подписки округляется до 0), поэтому откат запрещен, пока они есть.
+-- Их нужно перевести на помесячное списание или удалить вручную.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM subscriptions WHERE billing_period <> 'monthly') THEN
+        RAISE EXCEPTION 'cannot drop subscriptions.billing_period: subscriptions billed other than monthly exist';
+    END IF;
+END
+$$;
+
+-- У помесячных подписок цена списания и есть месячная стоимость, и она
+-- положительна, поэтому прежнее ограничение monthly_cost > 0 выполняется.
+UPDATE subscriptions SET monthly_cost = price;
+
+ALTER TABLE subscription_prices RENAME COLUMN price TO monthly_cost;
+
+COMMENT ON TABLE subscription_prices IS 'Изменения месячной стоимости подписок';
+COMMENT ON COLUMN subscription_prices.monthly_cost IS 'Месячная стоимость в валюте подписки начиная с effective_from';
+
+ALTER TABLE subscriptions
+    DROP CONSTRAINT IF EXISTS subscriptions_monthly_cost_check,
+    ADD CONSTRAINT subscriptions_monthly_cost_check CHECK (monthly_cost > 0),
+    DROP COLUMN IF EXISTS price,
+    DROP COLUMN IF EXISTS billing_period;
+
+COMMENT ON COLUMN subscriptions.monthly_cost IS 'Месячная стоимость в валюте currency';
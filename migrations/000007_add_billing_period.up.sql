@@ -0,0 +1,20 @@
+ALTER TABLE subscriptions
+    ADD COLUMN billing_period TEXT NOT NULL DEFAULT 'monthly'
+        CHECK (billing_period IN ('weekly', 'monthly', 'quarterly', 'yearly')),
+    ADD COLUMN price INTEGER NULL CHECK (price > 0);
+
+UPDATE subscriptions SET price = monthly_cost;
+
+ALTER TABLE subscriptions
+    ALTER COLUMN price SET NOT NULL,
+    DROP CONSTRAINT IF EXISTS subscriptions_monthly_cost_check,
+    ADD CONSTRAINT subscriptions_monthly_cost_check CHECK (monthly_cost >= 0);
+
+COMMENT ON COLUMN subscriptions.billing_period IS 'Период списания: weekly, monthly, quarterly, yearly';
+COMMENT ON COLUMN subscriptions.price IS 'Сумма одного списания в валюте currency';
+COMMENT ON COLUMN subscriptions.monthly_cost IS 'Месячный эквивалент стоимости в валюте currency';
+
+ALTER TABLE subscription_prices RENAME COLUMN monthly_cost TO price;
+
+COMMENT ON TABLE subscription_prices IS 'Изменения стоимости подписок';
+COMMENT ON COLUMN subscription_prices.price IS 'Сумма одного списания в валюте подписки начиная с effective_from';
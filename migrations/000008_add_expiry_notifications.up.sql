@@ -0,0 +1,17 @@
+CREATE TABLE expiry_notifications (
+                                      id BIGSERIAL PRIMARY KEY,
+                                      subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+                                      end_date DATE NOT NULL,
+                                      channel TEXT NOT NULL,
+                                      status TEXT NOT NULL CHECK (status IN ('sent', 'failed')),
+                                      attempts INTEGER NOT NULL DEFAULT 0,
+                                      last_error TEXT NULL,
+                                      sent_at TIMESTAMPTZ NULL,
+                                      updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                                      UNIQUE (subscription_id, end_date, channel)
+);
+
+COMMENT ON TABLE expiry_notifications IS 'Напоминания об окончании подписок';
+COMMENT ON COLUMN expiry_notifications.end_date IS 'Дата окончания, о которой напомнили; при ее изменении напоминание отправляется заново';
+COMMENT ON COLUMN expiry_notifications.channel IS 'Способ доставки: log, webhook, smtp';
+COMMENT ON COLUMN expiry_notifications.attempts IS 'Число попыток доставки';
@@ -0,0 +1,36 @@
+CREATE TABLE webhooks (
+                          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                          url TEXT NOT NULL,
+                          secret TEXT NOT NULL,
+                          events JSONB NOT NULL DEFAULT '[]',
+                          active BOOLEAN NOT NULL DEFAULT true,
+                          created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                          updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+COMMENT ON TABLE webhooks IS 'Получатели событий подписок';
+COMMENT ON COLUMN webhooks.secret IS 'Ключ подписи HMAC-SHA256';
+COMMENT ON COLUMN webhooks.events IS 'События для отправки; пустой массив означает все события';
+
+CREATE TABLE webhook_deliveries (
+                                    id BIGSERIAL PRIMARY KEY,
+                                    webhook_id UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+                                    subscription_id UUID NOT NULL,
+                                    event TEXT NOT NULL,
+                                    payload JSONB NOT NULL,
+                                    dedupe_key TEXT NULL,
+                                    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+                                    attempts INTEGER NOT NULL DEFAULT 0,
+                                    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                                    last_error TEXT NULL,
+                                    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                                    delivered_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_dead ON webhook_deliveries (webhook_id, id) WHERE status = 'dead';
+CREATE UNIQUE INDEX idx_webhook_deliveries_dedupe ON webhook_deliveries (webhook_id, dedupe_key) WHERE dedupe_key IS NOT NULL;
+
+COMMENT ON TABLE webhook_deliveries IS 'Исходящие события (outbox), записываются в одной транзакции с изменением подписки';
+COMMENT ON COLUMN webhook_deliveries.dedupe_key IS 'Ключ, исключающий повторную постановку одного события';
+COMMENT ON COLUMN webhook_deliveries.status IS 'pending - ожидает отправки, delivered - доставлено, dead - попытки исчерпаны';
@@ -0,0 +1,16 @@
+CREATE TABLE api_keys (
+                          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                          name TEXT NOT NULL,
+                          prefix TEXT NOT NULL,
+                          hash TEXT NOT NULL UNIQUE,
+                          scopes JSONB NOT NULL DEFAULT '[]',
+                          user_id UUID NULL,
+                          created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                          last_used_at TIMESTAMPTZ NULL,
+                          revoked_at TIMESTAMPTZ NULL
+);
+
+COMMENT ON TABLE api_keys IS 'Ключи доступа для межсервисных запросов';
+COMMENT ON COLUMN api_keys.prefix IS 'Начало ключа для отображения';
+COMMENT ON COLUMN api_keys.hash IS 'SHA-256 ключа в hex; сам ключ не хранится';
+COMMENT ON COLUMN api_keys.user_id IS 'Пользователь, от имени которого действует ключ; NULL - доступ ко всем пользователям';
@@ -0,0 +1,9 @@
+-- Исход отправки зарезервированных напоминаний неизвестен; считаем их
+-- отправленными, чтобы не напомнить повторно.
+UPDATE expiry_notifications SET status = 'sent' WHERE status = 'sending';
+
+ALTER TABLE expiry_notifications
+    DROP CONSTRAINT IF EXISTS expiry_notifications_status_check,
+    ADD CONSTRAINT expiry_notifications_status_check CHECK (status IN ('sent', 'failed'));
+
+COMMENT ON COLUMN expiry_notifications.status IS NULL;
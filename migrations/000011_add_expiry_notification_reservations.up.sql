@@ -0,0 +1,5 @@
+ALTER TABLE expiry_notifications
+    DROP CONSTRAINT IF EXISTS expiry_notifications_status_check,
+    ADD CONSTRAINT expiry_notifications_status_check CHECK (status IN ('sending', 'sent', 'failed'));
+
+COMMENT ON COLUMN expiry_notifications.status IS 'sending - напоминание зарезервировано перед отправкой, sent - доставлено, failed - не доставлено';
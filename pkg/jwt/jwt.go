@@ -0,0 +1,263 @@
+// Package jwt verifies compact JWS tokens signed with HS256 or RS256.
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed        = errors.New("malformed token")
+	ErrUnsupportedAlg   = errors.New("unsupported signing algorithm")
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpired          = errors.New("token is expired")
+	ErrNotYetValid      = errors.New("token is not valid yet")
+	ErrInvalidIssuer    = errors.New("invalid issuer")
+	ErrInvalidAudience  = errors.New("invalid audience")
+)
+
+// Claims are the decoded payload of a token.
+type Claims map[string]any
+
+// String returns a string claim or "".
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns a claim holding a string or a list of strings.
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func (c Claims) time(name string) (time.Time, bool) {
+	n, ok := c[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+type Config struct {
+	// HMACSecret enables HS256 tokens.
+	HMACSecret []byte
+	// RSAKeys enables RS256 tokens, keyed by the "kid" header. A token
+	// without kid is accepted when there is a single key.
+	RSAKeys map[string]*rsa.PublicKey
+	// Issuer and Audience are checked when set.
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew in exp and nbf.
+	Leeway time.Duration
+}
+
+type Verifier struct {
+	cfg Config
+	now func() time.Time
+}
+
+func NewVerifier(cfg Config) (*Verifier, error) {
+	if len(cfg.HMACSecret) == 0 && len(cfg.RSAKeys) == 0 {
+		return nil, errors.New("jwt: no HMAC secret or RSA keys configured")
+	}
+	return &Verifier{cfg: cfg, now: time.Now}, nil
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks the signature and the registered claims of token and
+// returns its claims. exp is required.
+func (v *Verifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, ErrMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if err := v.verifySignature(h, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformed
+	}
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *Verifier) verifySignature(h header, signed, signature []byte) error {
+	digest := sha256.Sum256(signed)
+
+	switch h.Alg {
+	case "HS256":
+		if len(v.cfg.HMACSecret) == 0 {
+			return ErrUnsupportedAlg
+		}
+		mac := hmac.New(sha256.New, v.cfg.HMACSecret)
+		mac.Write(signed)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case "RS256":
+		key, err := v.rsaKey(h.Kid)
+		if err != nil {
+			return err
+		}
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	default:
+		return ErrUnsupportedAlg
+	}
+}
+
+func (v *Verifier) rsaKey(kid string) (*rsa.PublicKey, error) {
+	if len(v.cfg.RSAKeys) == 0 {
+		return nil, ErrUnsupportedAlg
+	}
+	if key, ok := v.cfg.RSAKeys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(v.cfg.RSAKeys) == 1 {
+		for _, key := range v.cfg.RSAKeys {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+func (v *Verifier) validate(claims Claims) error {
+	now := v.now()
+
+	exp, ok := claims.time("exp")
+	if !ok {
+		return fmt.Errorf("%w: missing exp", ErrMalformed)
+	}
+	if !now.Before(exp.Add(v.cfg.Leeway)) {
+		return ErrExpired
+	}
+	if nbf, ok := claims.time("nbf"); ok && now.Add(v.cfg.Leeway).Before(nbf) {
+		return ErrNotYetValid
+	}
+	if v.cfg.Issuer != "" && claims.String("iss") != v.cfg.Issuer {
+		return ErrInvalidIssuer
+	}
+	if v.cfg.Audience != "" && !slices.Contains(claims.Strings("aud"), v.cfg.Audience) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("trailing data after JSON")
+	}
+	return nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// LoadJWKS reads the RSA signing keys of a JWK Set file. Keys of other types
+// or for encryption are skipped.
+func LoadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwt: parse %s: %w", path, err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") || (k.Alg != "" && k.Alg != "RS256") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil || len(n) == 0 {
+			return nil, fmt.Errorf("jwt: key %q: invalid modulus", k.Kid)
+		}
+		// The exponent must be odd and at least 3; rsa rejects the key
+		// otherwise only when a token is verified.
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("jwt: key %q: invalid exponent", k.Kid)
+		}
+		exponent := new(big.Int).SetBytes(e).Int64()
+		if exponent < 3 || exponent%2 == 0 {
+			return nil, fmt.Errorf("jwt: key %q: invalid exponent", k.Kid)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(exponent),
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwt: no RSA signing keys in %s", path)
+	}
+	return keys, nil
+}
@@ -0,0 +1,331 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	now    = time.Unix(1_700_000_000, 0)
+	secret = []byte("0123456789abcdef0123456789abcdef")
+	key1   = generateKey()
+	key2   = generateKey()
+)
+
+func generateKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func segment(v any) string {
+	if s, ok := v.(string); ok {
+		return encode([]byte(s))
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return encode(data)
+}
+
+// hs256 signs the header and claims, each a value or raw JSON, with the
+// HMAC secret.
+func hs256(secret []byte, header, claims any) string {
+	signed := segment(header) + "." + segment(claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + encode(mac.Sum(nil))
+}
+
+func rs256(key *rsa.PrivateKey, header, claims any) string {
+	signed := segment(header) + "." + segment(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + encode(signature)
+}
+
+func valid(extra map[string]any) map[string]any {
+	claims := map[string]any{"sub": "user", "exp": now.Add(time.Hour).Unix()}
+	for name, value := range extra {
+		claims[name] = value
+	}
+	return claims
+}
+
+func newTestVerifier(t *testing.T, cfg Config) *Verifier {
+	t.Helper()
+	cfg.Leeway = 30 * time.Second
+	v, err := NewVerifier(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.now = func() time.Time { return now }
+	return v
+}
+
+func TestVerifyAlgorithms(t *testing.T) {
+	hsOnly := Config{HMACSecret: secret}
+	rsOnly := Config{RSAKeys: map[string]*rsa.PublicKey{"k1": &key1.PublicKey}}
+	both := Config{HMACSecret: secret, RSAKeys: map[string]*rsa.PublicKey{"k1": &key1.PublicKey}}
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(&key1.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hs := map[string]string{"alg": "HS256"}
+	rs := map[string]string{"alg": "RS256", "kid": "k1"}
+	tests := []struct {
+		name  string
+		cfg   Config
+		token string
+		want  error
+	}{
+		{"HS256", hsOnly, hs256(secret, hs, valid(nil)), nil},
+		{"RS256", rsOnly, rs256(key1, rs, valid(nil)), nil},
+		{"HS256 and RS256 accepted together", both, rs256(key1, rs, valid(nil)), nil},
+		{"HS256 without a secret", rsOnly, hs256(secret, hs, valid(nil)), ErrUnsupportedAlg},
+		{"RS256 without keys", hsOnly, rs256(key1, rs, valid(nil)), ErrUnsupportedAlg},
+		{"HS256 keyed with the RSA public key", rsOnly, hs256(publicKeyDER, hs, valid(nil)), ErrUnsupportedAlg},
+		{"HS256 keyed with the RSA public key next to a secret", both, hs256(publicKeyDER, hs, valid(nil)), ErrInvalidSignature},
+		{"RS256 header with an HMAC signature", both, hs256(secret, rs, valid(nil)), ErrInvalidSignature},
+		{"HS256 with a wrong secret", hsOnly, hs256([]byte("another secret of enough length!"), hs, valid(nil)), ErrInvalidSignature},
+		{"alg none", both, segment(`{"alg":"none"}`) + "." + segment(valid(nil)) + ".", ErrUnsupportedAlg},
+		{"alg None", both, segment(`{"alg":"None"}`) + "." + segment(valid(nil)) + ".", ErrUnsupportedAlg},
+		{"alg missing", both, hs256(secret, `{}`, valid(nil)), ErrUnsupportedAlg},
+		{"alg in lower case", both, hs256(secret, `{"alg":"hs256"}`, valid(nil)), ErrUnsupportedAlg},
+		{"HS512", both, hs256(secret, `{"alg":"HS512"}`, valid(nil)), ErrUnsupportedAlg},
+		{"tampered claims", hsOnly, strings.Replace(hs256(secret, hs, valid(nil)), segment(valid(nil)), segment(valid(map[string]any{"sub": "admin"})), 1), ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestVerifier(t, tt.cfg).Verify(tt.token)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyKeyLookup(t *testing.T) {
+	single := Config{RSAKeys: map[string]*rsa.PublicKey{"k1": &key1.PublicKey}}
+	two := Config{RSAKeys: map[string]*rsa.PublicKey{"k1": &key1.PublicKey, "k2": &key2.PublicKey}}
+
+	tests := []struct {
+		name  string
+		cfg   Config
+		token string
+		want  error
+	}{
+		{"kid of the single key", single, rs256(key1, `{"alg":"RS256","kid":"k1"}`, valid(nil)), nil},
+		{"no kid with a single key", single, rs256(key1, `{"alg":"RS256"}`, valid(nil)), nil},
+		{"unknown kid with a single key", single, rs256(key1, `{"alg":"RS256","kid":"k9"}`, valid(nil)), ErrUnknownKey},
+		{"kid among several keys", two, rs256(key2, `{"alg":"RS256","kid":"k2"}`, valid(nil)), nil},
+		{"no kid with several keys", two, rs256(key1, `{"alg":"RS256"}`, valid(nil)), ErrUnknownKey},
+		{"kid of another key", two, rs256(key2, `{"alg":"RS256","kid":"k1"}`, valid(nil)), ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestVerifier(t, tt.cfg).Verify(tt.token)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyClaims(t *testing.T) {
+	unix := func(d time.Duration) int64 { return now.Add(d).Unix() }
+	checked := Config{HMACSecret: secret, Issuer: "https://auth.example.com", Audience: "subscriptions"}
+	issued := map[string]any{"iss": "https://auth.example.com", "aud": "subscriptions"}
+	with := func(claims map[string]any) map[string]any {
+		for name, value := range issued {
+			if _, ok := claims[name]; !ok {
+				claims[name] = value
+			}
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name   string
+		claims any
+		want   error
+	}{
+		{"valid", with(valid(nil)), nil},
+		{"expired", with(valid(map[string]any{"exp": unix(-time.Minute)})), ErrExpired},
+		{"expired within leeway", with(valid(map[string]any{"exp": unix(-10 * time.Second)})), nil},
+		{"expired at the end of leeway", with(valid(map[string]any{"exp": unix(-30 * time.Second)})), ErrExpired},
+		{"fractional exp", `{"exp": 1700003600.5, "iss": "https://auth.example.com", "aud": "subscriptions"}`, nil},
+		{"missing exp", with(map[string]any{"sub": "user"}), ErrMalformed},
+		{"exp as a string", with(map[string]any{"exp": "1700003600"}), ErrMalformed},
+		{"not yet valid", with(valid(map[string]any{"nbf": unix(time.Minute)})), ErrNotYetValid},
+		{"not yet valid within leeway", with(valid(map[string]any{"nbf": unix(10 * time.Second)})), nil},
+		{"valid since nbf", with(valid(map[string]any{"nbf": unix(-time.Minute)})), nil},
+		{"wrong issuer", with(valid(map[string]any{"iss": "https://evil.example.com"})), ErrInvalidIssuer},
+		{"missing issuer", valid(map[string]any{"aud": "subscriptions"}), ErrInvalidIssuer},
+		{"audience list", with(valid(map[string]any{"aud": []string{"billing", "subscriptions"}})), nil},
+		{"wrong audience", with(valid(map[string]any{"aud": "billing"})), ErrInvalidAudience},
+		{"audience list without ours", with(valid(map[string]any{"aud": []string{"billing"}})), ErrInvalidAudience},
+		{"missing audience", valid(map[string]any{"iss": "https://auth.example.com"}), ErrInvalidAudience},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestVerifier(t, checked).Verify(hs256(secret, `{"alg":"HS256"}`, tt.claims))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("unchecked issuer and audience", func(t *testing.T) {
+		token := hs256(secret, `{"alg":"HS256"}`, valid(map[string]any{"iss": "anyone", "aud": []string{"anything"}}))
+		claims, err := newTestVerifier(t, Config{HMACSecret: secret}).Verify(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims.String("iss") != "anyone" || strings.Join(claims.Strings("aud"), ",") != "anything" {
+			t.Errorf("claims = %v", claims)
+		}
+	})
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	header, claims := segment(`{"alg":"HS256"}`), segment(valid(nil))
+	sign := func(signed string) string {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signed))
+		return signed + "." + encode(mac.Sum(nil))
+	}
+	good := sign(header + "." + claims)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"two segments", header + "." + claims},
+		{"four segments", good + ".x"},
+		{"header not base64", sign("!!." + claims)},
+		{"header not JSON", sign(segment("alg=HS256") + "." + claims)},
+		{"header with trailing data", sign(segment(`{"alg":"HS256"} {}`) + "." + claims)},
+		{"header as a string", sign(segment(`"HS256"`) + "." + claims)},
+		{"claims not base64", sign(header + ".!!")},
+		{"claims padded", sign(header + "." + base64.URLEncoding.EncodeToString([]byte(`{"exp": 1700003600}`)))},
+		{"claims not JSON", sign(header + "." + segment(`{"exp":`))},
+		{"claims with trailing data", sign(header + "." + segment(`{"exp":1700003600}x`))},
+		{"claims as an array", sign(header + "." + segment(`[1700003600]`))},
+		{"claims null", sign(header + "." + segment(`null`))},
+		{"signature not base64", header + "." + claims + ".!!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestVerifier(t, Config{HMACSecret: secret}).Verify(tt.token)
+			if !errors.Is(err, ErrMalformed) {
+				t.Fatalf("err = %v, want ErrMalformed", err)
+			}
+		})
+	}
+}
+
+func TestNewVerifierRequiresKeys(t *testing.T) {
+	if _, err := NewVerifier(Config{Issuer: "https://auth.example.com"}); err == nil {
+		t.Fatal("verifier without keys was created")
+	}
+}
+
+func TestLoadJWKS(t *testing.T) {
+	n := encode(key1.PublicKey.N.Bytes())
+	rsaKey := func(kid, e string) map[string]string {
+		return map[string]string{"kty": "RSA", "kid": kid, "use": "sig", "alg": "RS256", "n": n, "e": e}
+	}
+	write := func(t *testing.T, keys ...any) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		data, err := json.Marshal(map[string]any{"keys": keys})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("signing keys", func(t *testing.T) {
+		path := write(t,
+			rsaKey("k1", "AQAB"),
+			map[string]string{"kty": "RSA", "kid": "k2", "n": encode(key2.PublicKey.N.Bytes()), "e": "AQAB"},
+			map[string]string{"kty": "RSA", "kid": "enc", "use": "enc", "n": n, "e": "AQAB"},
+			map[string]string{"kty": "RSA", "kid": "ps", "alg": "PS256", "n": n, "e": "AQAB"},
+			map[string]string{"kty": "EC", "kid": "ec", "crv": "P-256", "x": "AA", "y": "AA"},
+		)
+		keys, err := LoadJWKS(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 2 || !keys["k1"].Equal(&key1.PublicKey) || !keys["k2"].Equal(&key2.PublicKey) {
+			t.Fatalf("keys = %v", keys)
+		}
+
+		v := newTestVerifier(t, Config{RSAKeys: keys})
+		if _, err := v.Verify(rs256(key2, `{"alg":"RS256","kid":"k2"}`, valid(nil))); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	tests := []struct {
+		name string
+		keys []any
+	}{
+		{"empty exponent", []any{rsaKey("k1", "")}},
+		{"zero exponent", []any{rsaKey("k1", "AA")}},
+		{"exponent 1", []any{rsaKey("k1", "AQ")}},
+		{"even exponent", []any{rsaKey("k1", "AAE")}},
+		{"exponent longer than 4 bytes", []any{rsaKey("k1", encode(big.NewInt(1<<40+1).Bytes()))}},
+		{"exponent not base64", []any{rsaKey("k1", "AQ=B")}},
+		{"modulus not base64", []any{map[string]string{"kty": "RSA", "kid": "k1", "n": "!!", "e": "AQAB"}}},
+		{"empty modulus", []any{map[string]string{"kty": "RSA", "kid": "k1", "n": "", "e": "AQAB"}}},
+		{"no signing keys", []any{map[string]string{"kty": "RSA", "kid": "enc", "use": "enc", "n": n, "e": "AQAB"}}},
+		{"no keys", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if keys, err := LoadJWKS(write(t, tt.keys...)); err == nil {
+				t.Fatalf("loaded %v", keys)
+			}
+		})
+	}
+
+	t.Run("not JSON", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		if err := os.WriteFile(path, []byte("keys:"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadJWKS(path); err == nil {
+			t.Fatal("loaded a file that is not JSON")
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/rezexell/em-test-task/internal/testdb"
+	"gorm.io/gorm"
+)
+
+const insertSubscription = `INSERT INTO subscriptions (service_name, monthly_cost, price, user_id, start_date, deleted_at)
+	VALUES ('Netflix', 100, 100, gen_random_uuid(), DATE '2024-01-01', ?)`
+
+func hasColumn(t *testing.T, db *gorm.DB, table, column string) bool {
+	t.Helper()
+	var n int64
+	err := db.Raw(`SELECT count(*) FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`, table, column).Scan(&n).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n == 1
+}
+
+func TestSoftDeleteDownMigrationKeepsDeletedSubscriptions(t *testing.T) {
+	db, m := testdb.Migrator(t)
+	if err := db.Exec(insertSubscription, "2024-06-01").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Migrate(2); err == nil {
+		t.Fatal("migrating below 3 succeeded with soft-deleted subscriptions")
+	}
+
+	var n int64
+	if err := db.Raw("SELECT count(*) FROM subscriptions WHERE deleted_at IS NOT NULL").Scan(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("%d soft-deleted subscriptions left, want 1", n)
+	}
+}
+
+func TestSoftDeleteDownMigrationWithoutDeletedSubscriptions(t *testing.T) {
+	db, m := testdb.Migrator(t)
+	if err := db.Exec(insertSubscription, nil).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Migrate(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if hasColumn(t, db, "subscriptions", "deleted_at") {
+		t.Error("deleted_at was not dropped")
+	}
+	var n int64
+	if err := db.Raw("SELECT count(*) FROM subscriptions").Scan(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("%d subscriptions left, want 1", n)
+	}
+}
+
+const insertBilledSubscription = `INSERT INTO subscriptions (service_name, monthly_cost, price, billing_period, user_id, start_date)
+	VALUES ('Netflix', ?, ?, ?, gen_random_uuid(), DATE '2024-01-01')`
+
+func TestBillingPeriodDownMigrationKeepsOtherPeriods(t *testing.T) {
+	db, m := testdb.Migrator(t)
+	// A yearly price of 5 is a monthly equivalent of 0.
+	if err := db.Exec(insertBilledSubscription, 0, 5, "yearly").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Migrate(6); err == nil {
+		t.Fatal("migrating below 7 succeeded with yearly subscriptions")
+	}
+
+	var period string
+	if err := db.Raw("SELECT billing_period FROM subscriptions").Scan(&period).Error; err != nil {
+		t.Fatal(err)
+	}
+	if period != "yearly" {
+		t.Errorf("billing_period = %q, want yearly", period)
+	}
+}
+
+func TestBillingPeriodDownMigrationWithMonthlySubscriptions(t *testing.T) {
+	db, m := testdb.Migrator(t)
+	if err := db.Exec(insertBilledSubscription, 400, 400, "monthly").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Migrate(6); err != nil {
+		t.Fatal(err)
+	}
+
+	if hasColumn(t, db, "subscriptions", "billing_period") || hasColumn(t, db, "subscriptions", "price") {
+		t.Error("billing_period and price were not dropped")
+	}
+	var cost int
+	if err := db.Raw("SELECT monthly_cost FROM subscriptions").Scan(&cost).Error; err != nil {
+		t.Fatal(err)
+	}
+	if cost != 400 {
+		t.Errorf("monthly_cost = %d, want 400", cost)
+	}
+}
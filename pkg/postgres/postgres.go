@@ -16,13 +16,13 @@ import (
 )
 
 func getConnString(cfg *config.Config) string {
-	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
+	return fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable",
 		cfg.DBUSER, cfg.DBPASSWORD, cfg.DBHOST, cfg.DBPORT, cfg.DBNAME)
 }
 
 func InitDB(cfg *config.Config, logger *slog.Logger) *gorm.DB {
 	dsn := getConnString(cfg)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		logger.Error("Unable to create GORM connection", slog.Any("err", err.Error()))
 		os.Exit(1)
@@ -43,6 +43,53 @@ func InitDB(cfg *config.Config, logger *slog.Logger) *gorm.DB {
 	return db
 }
 
+// Close closes the connection pool once the server has stopped using it.
+func Close(db *gorm.DB, logger *slog.Logger) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		logger.Error("Failed to get underlying DB:", slog.Any("err", err.Error()))
+		return
+	}
+
+	if err := sqlDB.Close(); err != nil {
+		logger.Error("Failed to close database connection:", slog.Any("err", err.Error()))
+		return
+	}
+	logger.Info("Database connection closed")
+}
+
+// HealthCheck returns a readiness check that pings the database and reports
+// the applied migration version. A dirty migration fails the check.
+func HealthCheck(db *gorm.DB) func(ctx context.Context) (map[string]any, error) {
+	return func(ctx context.Context) (map[string]any, error) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			return nil, err
+		}
+
+		var migration struct {
+			Version int64
+			Dirty   bool
+		}
+		result := db.WithContext(ctx).Raw("SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&migration)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, errors.New("no migrations applied")
+		}
+
+		details := map[string]any{"migration_version": migration.Version, "migration_dirty": migration.Dirty}
+		if migration.Dirty {
+			return details, fmt.Errorf("migration %d is dirty", migration.Version)
+		}
+		return details, nil
+	}
+}
+
 func ApplyMigrations(cfg *config.Config, logger *slog.Logger) {
 
 	m, err := migrate.New(
@@ -0,0 +1,154 @@
+// Package ratelimit implements token bucket rate limiting with pluggable
+// bucket storage.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit refills a bucket with Rate tokens per second up to Burst tokens.
+// Every request takes one token. The zero Limit disables limiting.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// String formats the limit in the syntax accepted by ParseLimit, preferring
+// the unit that makes the burst implicit.
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	formatted := ""
+	for _, unit := range []struct {
+		name string
+		per  time.Duration
+	}{{"s", time.Second}, {"m", time.Minute}, {"h", time.Hour}} {
+		count := l.Rate * unit.per.Seconds()
+		rounded := math.Round(count)
+		if rounded < 1 || math.Abs(count-rounded) > 1e-9 {
+			continue
+		}
+		if int(rounded) == l.Burst {
+			return fmt.Sprintf("%d/%s", l.Burst, unit.name)
+		}
+		if formatted == "" {
+			formatted = fmt.Sprintf("%d/%s:%d", int(rounded), unit.name, l.Burst)
+		}
+	}
+	if formatted == "" {
+		formatted = fmt.Sprintf("%g/s:%d", l.Rate, l.Burst)
+	}
+	return formatted
+}
+
+// ParseLimit parses "off" or "<count>/<unit>[:<burst>]" where unit is s, m
+// or h, such as "600/m" or "5/s:20". The burst defaults to count.
+func ParseLimit(spec string) (Limit, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "off" {
+		return Limit{}, nil
+	}
+
+	rate, burstSpec, hasBurst := strings.Cut(spec, ":")
+	countSpec, unit, ok := strings.Cut(rate, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: want <count>/<unit>[:<burst>]", spec)
+	}
+	count, err := strconv.Atoi(countSpec)
+	if err != nil || count <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: count must be a positive integer", spec)
+	}
+
+	var per time.Duration
+	switch unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("invalid rate limit %q: unit must be s, m or h", spec)
+	}
+
+	burst := count
+	if hasBurst {
+		if burst, err = strconv.Atoi(burstSpec); err != nil || burst <= 0 {
+			return Limit{}, fmt.Errorf("invalid rate limit %q: burst must be a positive integer", spec)
+		}
+	}
+
+	return Limit{Rate: float64(count) / per.Seconds(), Burst: burst}, nil
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is when the next token is available if the request was
+	// rejected.
+	RetryAfter time.Duration
+	// Reset is when the bucket is full again.
+	Reset time.Duration
+}
+
+// Store keeps the buckets. Take removes a token from the bucket of key if
+// there is one.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// result describes a bucket left with tokens after a request.
+func result(limit Limit, tokens float64, allowed bool) Result {
+	r := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(s, 0) * float64(time.Second))
+}
+
+// Limiter applies a default limit and per-route overrides. Clients share
+// one bucket for all routes without their own limit.
+type Limiter struct {
+	store  Store
+	limit  Limit
+	routes map[string]Limit
+}
+
+func New(store Store, limit Limit, routes map[string]Limit) *Limiter {
+	return &Limiter{store: store, limit: limit, routes: routes}
+}
+
+// Allow takes a token for a request of client to route. Requests to routes
+// without a limit are allowed with a zero Result.Limit.
+func (l *Limiter) Allow(ctx context.Context, route, client string) (Result, error) {
+	limit, key := l.limit, client+"|*"
+	if routeLimit, found := l.routes[route]; found {
+		limit, key = routeLimit, client+"|"+route
+	}
+	if !limit.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	return l.store.Take(ctx, key, limit)
+}
@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// RedisClient is the subset of a Redis client the store needs. It matches
+// an adapter around go-redis such as
+//
+//	func (a adapter) Eval(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+//		return a.client.Eval(ctx, script, keys, args...).Result()
+//	}
+type RedisClient interface {
+	Eval(ctx context.Context, script string, keys []string, args ...any) (any, error)
+}
+
+// takeScript refills and takes from the bucket hash atomically and expires
+// it once it would be full again.
+const takeScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}`
+
+// RedisStore keeps buckets in Redis or a compatible server, so that all
+// replicas share the limits.
+type RedisStore struct {
+	client RedisClient
+	prefix string
+}
+
+func NewRedisStore(client RedisClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	reply, err := s.client.Eval(ctx, takeScript, []string{s.prefix + key},
+		limit.Rate, limit.Burst, time.Now().UnixMilli())
+	if err != nil {
+		return Result{}, err
+	}
+
+	values, ok := reply.([]any)
+	if !ok || len(values) != 2 {
+		return Result{}, fmt.Errorf("ratelimit: unexpected reply %v", reply)
+	}
+	allowed, ok := values[0].(int64)
+	if !ok {
+		return Result{}, fmt.Errorf("ratelimit: unexpected reply %v", reply)
+	}
+	tokensReply, ok := values[1].(string)
+	if !ok {
+		return Result{}, fmt.Errorf("ratelimit: unexpected reply %v", reply)
+	}
+	tokens, err := strconv.ParseFloat(tokensReply, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("ratelimit: unexpected reply %v", reply)
+	}
+
+	return result(limit, tokens, allowed == 1), nil
+}
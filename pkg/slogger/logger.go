@@ -6,17 +6,15 @@ import (
 	"os"
 )
 
+// InitLogger returns a debug level JSON logger for the dev level and a warn
+// level text logger otherwise.
 func InitLogger(cfg *config.Config) *slog.Logger {
-	var logger *slog.Logger
-	switch cfg.LOGLEVEL {
-	case "dev":
-		logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+	if cfg.LOGLEVEL == "dev" {
+		return slog.New(traceHandler{slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 			Level: slog.LevelDebug,
-		}))
-	case "prod":
-		logger = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-			Level: slog.LevelWarn,
-		}))
+		})})
 	}
-	return logger
+	return slog.New(traceHandler{slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+	})})
 }
@@ -0,0 +1,112 @@
+// Package xlsx writes single-sheet XLSX workbooks row by row without keeping
+// the rows in memory.
+package xlsx
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var staticParts = []struct{ name, content string }{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+const workbookPart = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+// Writer writes the rows of a single sheet. Call Close to finish the file.
+type Writer struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+}
+
+// NewWriter starts a workbook with one sheet of the given name.
+func NewWriter(w io.Writer, sheetName string) (*Writer, error) {
+	zw := zip.NewWriter(w)
+
+	for _, part := range staticParts {
+		if err := writePart(zw, part.name, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := writePart(zw, "xl/workbook.xml", fmt.Sprintf(workbookPart, escape(sheetName))); err != nil {
+		return nil, err
+	}
+
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	xw := &Writer{zw: zw, sheet: bufio.NewWriter(sheet)}
+	_, err = xw.sheet.WriteString(xml.Header +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if err != nil {
+		return nil, err
+	}
+
+	return xw, nil
+}
+
+// WriteRow appends a row. Integers and floats become numeric cells, nil an
+// empty cell and any other value a string cell.
+func (w *Writer) WriteRow(cells ...any) error {
+	w.sheet.WriteString("<row>")
+	for _, cell := range cells {
+		switch v := cell.(type) {
+		case nil:
+			w.sheet.WriteString("<c/>")
+		case int:
+			w.sheet.WriteString(`<c t="n"><v>` + strconv.Itoa(v) + "</v></c>")
+		case float64:
+			w.sheet.WriteString(`<c t="n"><v>` + strconv.FormatFloat(v, 'f', -1, 64) + "</v></c>")
+		default:
+			w.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">` + escape(fmt.Sprint(v)) + "</t></is></c>")
+		}
+	}
+	_, err := w.sheet.WriteString("</row>")
+	return err
+}
+
+// Close finishes the sheet and the archive. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if _, err := w.sheet.WriteString("</sheetData></worksheet>"); err != nil {
+		return err
+	}
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+func writePart(zw *zip.Writer, name, content string) error {
+	part, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(part, content)
+	return err
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}